@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/auth"
+)
+
+// authMiddleware puts the principal of a valid bearer token into the
+// request context. Requests without a token pass through anonymously;
+// requests with an invalid one are rejected.
+func authMiddleware(verifier *auth.Verifier, log *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r.Header.Get("Authorization"))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := verify(verifier, token)
+		if err != nil {
+			log.Warn("rejected bearer token", "error", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errors":[{"message":"invalid token","extensions":{"code":"UNAUTHENTICATED"}}]}`))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// websocketAuth accepts the token from the connection_init payload, since
+// browsers cannot set headers on websocket requests.
+func websocketAuth(verifier *auth.Verifier) transport.WebsocketInitFunc {
+	return func(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+		token, ok := bearerToken(payload.Authorization())
+		if !ok {
+			return ctx, &payload, nil
+		}
+
+		principal, err := verify(verifier, token)
+		if err != nil {
+			return ctx, nil, err
+		}
+		return auth.WithPrincipal(ctx, principal), &payload, nil
+	}
+}
+
+func verify(verifier *auth.Verifier, token string) (*auth.Principal, error) {
+	if verifier == nil {
+		return nil, errors.New("token authentication is not configured")
+	}
+	return verifier.Verify(token)
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const readinessTimeout = 2 * time.Second
+
+// probes serves /healthz and /readyz. The server is live as long as it
+// answers; it is ready once started, until shutdown begins, and only while
+// every dependency check passes.
+type probes struct {
+	ready  atomic.Bool
+	checks map[string]func(context.Context) error
+}
+
+func newProbes() *probes {
+	return &probes{checks: map[string]func(context.Context) error{}}
+}
+
+// addCheck registers a dependency that must be reachable for the server to
+// be ready.
+func (p *probes) addCheck(name string, check func(context.Context) error) {
+	p.checks[name] = check
+}
+
+func (p *probes) healthz(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, http.StatusOK, map[string]any{"status": "ok"})
+}
+
+func (p *probes) readyz(w http.ResponseWriter, r *http.Request) {
+	if !p.ready.Load() {
+		writeStatus(w, http.StatusServiceUnavailable, map[string]any{"status": "shutting down"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	code, status := http.StatusOK, "ok"
+	results := make(map[string]string, len(p.checks))
+	for name, check := range p.checks {
+		if err := check(ctx); err != nil {
+			code, status = http.StatusServiceUnavailable, "unavailable"
+			results[name] = err.Error()
+			continue
+		}
+		results[name] = "ok"
+	}
+	writeStatus(w, code, map[string]any{"status": status, "checks": results})
+}
+
+func writeStatus(w http.ResponseWriter, code int, body map[string]any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
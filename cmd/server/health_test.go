@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/config"
+)
+
+func probe(t *testing.T, handler http.HandlerFunc) (int, map[string]any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return rec.Code, body
+}
+
+func TestProbes(t *testing.T) {
+	p := newProbes()
+	var redisErr error
+	p.addCheck("postgres", func(context.Context) error { return nil })
+	p.addCheck("redis", func(context.Context) error { return redisErr })
+
+	if code, _ := probe(t, p.healthz); code != http.StatusOK {
+		t.Fatalf("healthz: expected 200, got %d", code)
+	}
+
+	if code, body := probe(t, p.readyz); code != http.StatusServiceUnavailable || body["status"] != "shutting down" {
+		t.Fatalf("readyz: expected 503 before start, got %d %v", code, body)
+	}
+
+	p.ready.Store(true)
+	if code, body := probe(t, p.readyz); code != http.StatusOK || body["status"] != "ok" {
+		t.Fatalf("readyz: expected 200, got %d %v", code, body)
+	}
+
+	redisErr = errors.New("connection refused")
+	code, body := probe(t, p.readyz)
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz: expected 503 with a failing check, got %d", code)
+	}
+	checks, _ := body["checks"].(map[string]any)
+	if checks["redis"] != "connection refused" || checks["postgres"] != "ok" {
+		t.Fatalf("readyz: expected the failing check to be reported, got %v", body)
+	}
+
+	if code, _ := probe(t, p.healthz); code != http.StatusOK {
+		t.Fatalf("healthz: expected 200 while a dependency is down, got %d", code)
+	}
+}
+
+func TestUsesRedis(t *testing.T) {
+	cases := []struct {
+		pubsub, cache string
+		want          bool
+	}{
+		{"memory", "none", false},
+		{"memory", "memory", false},
+		{"redis", "none", true},
+		{"memory", "redis", true},
+		{"memory", "tiered", true},
+	}
+	for _, tc := range cases {
+		if got := usesRedis(&config.Config{PubSub: tc.pubsub, Cache: tc.cache}); got != tc.want {
+			t.Errorf("PUBSUB=%s CACHE=%s: expected %v, got %v", tc.pubsub, tc.cache, tc.want, got)
+		}
+	}
+}
@@ -2,10 +2,13 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"net"
 	"net/http"
 	"os"
-	"sync"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
@@ -15,68 +18,251 @@ import (
 	"github.com/99designs/gqlgen/graphql/playground"
 	_ "github.com/lib/pq"
 	"github.com/limon4ik-black/graphql-comments-system.git/graph"
-	"github.com/limon4ik-black/graphql-comments-system.git/graph/model"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/auth"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/authz"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/cache"
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/config"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/events"
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/logger"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/metrics"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pubsub"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/repository"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/repository/memory"
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/repository/postgres"
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/service"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/tracing"
 	"github.com/redis/go-redis/v9"
 	"github.com/vektah/gqlparser/v2/ast"
 )
 
 func main() {
-	cfg := config.Load()
-
 	log := logger.New()
 
+	cfg, err := config.Load()
+	if err != nil {
+		log.Error("invalid config", "error", err)
+		os.Exit(1)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter, cfg.OTLPEndpoint, "graphql-comments-system")
+	if err != nil {
+		log.Error("failed to set up tracing", "exporter", cfg.TracingExporter, "error", err)
+		os.Exit(1)
+	}
+	log.Info("tracing exporter selected", "exporter", cfg.TracingExporter)
+
 	redisClient := redis.NewClient(&redis.Options{
 		Addr: cfg.RedisAddr,
 	})
+	redisClient.AddHook(tracing.RedisHook{})
 
-	db, err := sql.Open("postgres", cfg.PostgresDSN)
-	if err != nil {
-		log.Error("failed to open postgres connection", "error", err)
+	var (
+		postRepo    repository.PostRepository
+		commentRepo repository.CommentRepository
+		db          *sql.DB
+	)
+
+	switch cfg.Storage {
+	case "memory":
+		store := memory.NewStore()
+		postRepo = memory.NewPostRepo(store)
+		commentRepo = memory.NewCommentRepo(store)
+	case "postgres":
+		db, err = sql.Open("postgres", cfg.PostgresDSN)
+		if err != nil {
+			log.Error("failed to open postgres connection", "error", err)
+			os.Exit(1)
+		}
+		postRepo = postgres.NewPostRepo(db)
+		commentRepo = postgres.NewCommentRepo(db)
+	default:
+		log.Error("unknown storage", "storage", cfg.Storage)
 		os.Exit(1)
 	}
+	log.Info("storage selected", "storage", cfg.Storage)
 
-	port := cfg.AppPort
+	var broker pubsub.Broker
+	var eventLog events.Log
+	switch cfg.PubSub {
+	case "memory":
+		broker = pubsub.NewMemoryBroker()
+		eventLog = events.NewMemoryLog(cfg.EventLogSize)
+	case "redis":
+		broker = pubsub.NewRedisBroker(redisClient)
+		eventLog = events.NewRedisLog(redisClient, cfg.EventLogSize)
+	default:
+		log.Error("unknown pubsub", "pubsub", cfg.PubSub)
+		os.Exit(1)
+	}
+	log.Info("pubsub selected", "pubsub", cfg.PubSub)
+
+	// appCtx lives until shutdown and bounds background work such as cache
+	// invalidation listeners.
+	appCtx, stopApp := context.WithCancel(context.Background())
 
-	postRepo := postgres.NewPostRepo(db)
-	commentRepo := postgres.NewCommentRepo(db)
+	var dataCache cache.Cache
+	switch cfg.Cache {
+	case "none":
+	case "redis":
+		dataCache = cache.NewRedis(redisClient)
+	case "memory", "tiered":
+		local, err := cache.NewLRU(cfg.LocalCacheSize)
+		if err != nil {
+			log.Error("failed to create local cache", "error", err)
+			os.Exit(1)
+		}
+		dataCache = local
+		if cfg.Cache == "tiered" {
+			dataCache, err = cache.NewTiered(appCtx, local, cache.NewRedis(redisClient), broker, cfg.LocalCacheTTL, log)
+			if err != nil {
+				log.Error("failed to subscribe to cache invalidations", "error", err)
+				os.Exit(1)
+			}
+		}
+	default:
+		log.Error("unknown cache", "cache", cfg.Cache)
+		os.Exit(1)
+	}
+	log.Info("cache selected", "cache", cfg.Cache)
 
-	postService := service.NewPostService(postRepo, commentRepo, redisClient, log)
-	commentService := service.NewCommentService(commentRepo, redisClient, postRepo, log)
+	var verifier *auth.Verifier
+	if cfg.JWTSecret != "" || cfg.JWKSFile != "" {
+		v, err := auth.NewVerifier(cfg.JWTSecret, cfg.JWKSFile)
+		if err != nil {
+			log.Error("failed to set up token verification", "error", err)
+			os.Exit(1)
+		}
+		verifier = v
+	} else {
+		log.Warn("JWT_SECRET and JWKS_FILE are not set, every caller is anonymous")
+	}
+
+	port := cfg.AppPort
+
+	policy := authz.DefaultPolicy()
+	cacheTTL := service.CacheTTL{Soft: cfg.CacheSoftTTL, Hard: cfg.CacheHardTTL}
+	bus := events.NewBus(broker, eventLog, log)
+	postService := service.NewPostService(postRepo, dataCache, cacheTTL, bus, policy, log)
+	commentService := service.NewCommentService(commentRepo, dataCache, cacheTTL, bus, postRepo, policy, cfg.MaxCommentDepth, log)
 
 	resolver := &graph.Resolver{
-		PostService:        postService,
-		CommentService:     commentService,
-		Redis:              redisClient,
-		CommentSubscribers: make(map[string][]chan *model.Comment),
-		Mu:                 sync.Mutex{},
-		Log:                log,
+		PostService:    postService,
+		CommentService: commentService,
+		Redis:          redisClient,
+		Events:         bus,
+		Log:            log,
 	}
 
-	srv := handler.New(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
+	schemaConfig := graph.Config{
+		Resolvers:  resolver,
+		Directives: graph.DirectiveRoot{HasRole: graph.HasRole},
+	}
+	graph.SetComplexity(&schemaConfig.Complexity)
+	srv := handler.New(graph.NewExecutableSchema(schemaConfig))
 
 	srv.AddTransport(transport.Options{})
 	srv.AddTransport(transport.GET{})
 	srv.AddTransport(transport.POST{})
 	srv.AddTransport(transport.Websocket{
 		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc:              websocketAuth(verifier),
 	})
 	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+	srv.AroundResponses(graph.LoadersMiddleware(postService, commentService))
+	srv.SetErrorPresenter(graph.NewErrorPresenter(log))
+	srv.SetRecoverFunc(graph.NewRecoverFunc(log))
+	srv.AroundFields(graph.MaskInternalErrors)
 
+	srv.Use(metrics.GraphQL{Operations: cfg.MetricsOperations})
+	srv.Use(tracing.GraphQL{})
+	srv.Use(graph.StreamErrors{})
 	srv.Use(extension.Introspection{})
+	srv.Use(graph.DepthLimit{Max: cfg.MaxQueryDepth})
+	srv.Use(extension.FixedComplexityLimit(cfg.MaxQueryComplexity))
 	srv.Use(extension.AutomaticPersistedQuery{
 		Cache: lru.New[string](100),
 	})
 
-	http.Handle("/", playground.Handler("GraphQL playground", "/query"))
-	http.Handle("/query", srv)
+	health := newProbes()
+	if db != nil {
+		health.addCheck("postgres", db.PingContext)
+	}
+	if usesRedis(cfg) {
+		health.addCheck("redis", func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		})
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", playground.Handler("GraphQL playground", "/query"))
+	mux.Handle("/query", authMiddleware(verifier, log, srv))
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", health.healthz)
+	mux.HandleFunc("/readyz", health.readyz)
 
-	log.Info("connect to http://localhost:" + port + "/ for GraphQL playground")
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
+	// Websocket connections outlive Shutdown, which only waits for plain
+	// requests. They get their own context, cancelled once those requests
+	// are drained.
+	connCtx, closeConns := context.WithCancel(context.Background())
+	httpServer := &http.Server{
+		Addr:         ":" + port,
+		Handler:      mux,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		BaseContext:  func(net.Listener) context.Context { return connCtx },
+	}
+
+	stop, cancelStop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Info("connect to http://localhost:" + port + "/ for GraphQL playground")
+		serveErr <- httpServer.ListenAndServe()
+	}()
+	health.ready.Store(true)
+
+	exitCode := 0
+	select {
+	case err := <-serveErr:
 		log.Error("HTTP server failed", "error", err)
-		os.Exit(1)
+		exitCode = 1
+	case <-stop.Done():
+		log.Info("shutting down")
 	}
+	cancelStop()
+	health.ready.Store(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Error("failed to drain requests", "error", err)
+		exitCode = 1
+	}
+	closeConns()
+	if err := resolver.WaitSubscriptions(ctx); err != nil {
+		log.Error("failed to close subscriptions", "error", err)
+		exitCode = 1
+	}
+	stopApp()
+
+	if db != nil {
+		if err := db.Close(); err != nil {
+			log.Error("failed to close postgres connection", "error", err)
+		}
+	}
+	if err := redisClient.Close(); err != nil {
+		log.Error("failed to close redis client", "error", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error("failed to flush traces", "error", err)
+	}
+	cancel()
+	log.Info("server stopped")
+	os.Exit(exitCode)
+}
+
+// usesRedis reports whether the broker, the event log or the cache is kept
+// in Redis, making it a dependency the server needs to be ready.
+func usesRedis(cfg *config.Config) bool {
+	return cfg.PubSub == "redis" || cfg.Cache == "redis" || cfg.Cache == "tiered"
 }
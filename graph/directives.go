@@ -0,0 +1,19 @@
+package graph
+
+import (
+	"context"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/limon4ik-black/graphql-comments-system.git/graph/model"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/authz"
+)
+
+// HasRole implements @hasRole: the field resolves only for callers with
+// the role, others get a FORBIDDEN error.
+func HasRole(ctx context.Context, obj any, next graphql.Resolver, role model.Role) (any, error) {
+	if err := authz.RequireRole(ctx, strings.ToLower(string(role))); err != nil {
+		return nil, err
+	}
+	return next(ctx)
+}
@@ -0,0 +1,57 @@
+package graph
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/auth"
+)
+
+func TestHasRole_LockComment(t *testing.T) {
+	srv := handler.New(NewExecutableSchema(Config{
+		Resolvers:  &Resolver{},
+		Directives: DirectiveRoot{HasRole: HasRole},
+	}))
+	srv.AddTransport(transport.POST{})
+	srv.SetErrorPresenter(NewErrorPresenter(slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+	cases := []struct {
+		name      string
+		principal *auth.Principal
+		wantCode  string
+	}{
+		{"anonymous", nil, "UNAUTHENTICATED"},
+		{"without the role", &auth.Principal{Subject: "bob", Roles: []string{"editor"}}, "FORBIDDEN"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := `{"query": "mutation { lockComment(id: \"1\", locked: true) { id } }"}`
+			req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), tc.principal))
+			}
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			var resp struct {
+				Errors []struct {
+					Extensions map[string]any `json:"extensions"`
+				} `json:"errors"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != tc.wantCode {
+				t.Fatalf("expected %s, got %s", tc.wantCode, rec.Body.String())
+			}
+		})
+	}
+}
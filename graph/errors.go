@@ -0,0 +1,113 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/auth"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/events"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/validation"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const internalErrorMessage = "internal server error"
+
+// internalError marks resolver errors that must not reach clients, such as
+// database failures.
+type internalError struct {
+	err error
+}
+
+func (e *internalError) Error() string { return e.err.Error() }
+
+func (e *internalError) Unwrap() error { return e.err }
+
+// errorCode returns the extensions.code of errors clients are expected to
+// handle, or "" for everything else.
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return "UNAUTHENTICATED"
+	case errors.Is(err, domain.ErrForbidden):
+		return "FORBIDDEN"
+	case errors.Is(err, domain.ErrNotFound):
+		return "NOT_FOUND"
+	case errors.Is(err, domain.ErrValidation):
+		return "VALIDATION_FAILED"
+	case errors.Is(err, domain.ErrCommentsDisabled):
+		return "COMMENTS_DISABLED"
+	case errors.Is(err, domain.ErrConflict):
+		return "CONFLICT"
+	case errors.Is(err, events.ErrLagged):
+		return "SUBSCRIPTION_LAGGED"
+	case errors.Is(err, errShuttingDown):
+		return "SHUTTING_DOWN"
+	}
+	return ""
+}
+
+// MaskInternalErrors is a field middleware that marks resolver errors
+// without a code as internal. Errors gqlgen itself reports for invalid
+// queries and arguments never pass through it and are kept as they are.
+func MaskInternalErrors(ctx context.Context, next graphql.Resolver) (any, error) {
+	res, err := next(ctx)
+	if err != nil && errorCode(err) == "" {
+		var gqlErr *gqlerror.Error
+		if !errors.As(err, &gqlErr) {
+			err = &internalError{err: err}
+		}
+	}
+	return res, err
+}
+
+// NewErrorPresenter tags errors clients are expected to handle with an
+// extensions.code and replaces internal ones with a generic message.
+// Validation errors also list the rejected input fields in
+// extensions.fields.
+func NewErrorPresenter(log *slog.Logger) graphql.ErrorPresenterFunc {
+	return func(ctx context.Context, err error) *gqlerror.Error {
+		gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+		var internal *internalError
+		if errors.As(err, &internal) {
+			log.Error("request failed", "path", gqlErr.Path.String(), "error", internal.err)
+			gqlErr.Message = internalErrorMessage
+			setCode(gqlErr, "INTERNAL_SERVER_ERROR")
+			return gqlErr
+		}
+
+		if code := errorCode(err); code != "" {
+			setCode(gqlErr, code)
+		}
+		var fieldErrs validation.Errors
+		if errors.As(err, &fieldErrs) {
+			fields := make([]map[string]any, 0, len(fieldErrs))
+			for _, fe := range fieldErrs {
+				fields = append(fields, map[string]any{"field": fe.Field, "message": fe.Message})
+			}
+			gqlErr.Extensions["fields"] = fields
+		}
+		return gqlErr
+	}
+}
+
+// NewRecoverFunc logs panics in resolvers and reports them to the client
+// as internal errors.
+func NewRecoverFunc(log *slog.Logger) graphql.RecoverFunc {
+	return func(ctx context.Context, p any) error {
+		log.Error("panic in resolver", "panic", p, "stack", string(debug.Stack()))
+		return &internalError{err: fmt.Errorf("panic: %v", p)}
+	}
+}
+
+func setCode(err *gqlerror.Error, code string) {
+	if err.Extensions == nil {
+		err.Extensions = map[string]any{}
+	}
+	err.Extensions["code"] = code
+}
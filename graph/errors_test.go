@@ -0,0 +1,83 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/auth"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/events"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/validation"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func TestErrorPresenter(t *testing.T) {
+	present := NewErrorPresenter(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	resolve := func(err error) error {
+		_, err = MaskInternalErrors(context.Background(), func(ctx context.Context) (any, error) {
+			return nil, err
+		})
+		return err
+	}
+
+	cases := []struct {
+		name        string
+		err         error
+		wantCode    any
+		wantMessage string
+	}{
+		{"not found", domain.NotFound("post"), "NOT_FOUND", "post not found"},
+		{"validation", domain.Validation("id is required"), "VALIDATION_FAILED", "id is required"},
+		{"comments disabled", domain.ErrCommentsDisabled, "COMMENTS_DISABLED", domain.ErrCommentsDisabled.Error()},
+		{"forbidden", domain.ErrForbidden, "FORBIDDEN", "forbidden"},
+		{"conflict", domain.Conflict("comment is deleted"), "CONFLICT", "comment is deleted"},
+		{"lagged", events.ErrLagged, "SUBSCRIPTION_LAGGED", events.ErrLagged.Error()},
+		{"shutting down", errShuttingDown, "SHUTTING_DOWN", errShuttingDown.Error()},
+		{"wrapped", fmt.Errorf("load: %w", domain.NotFound("comment")), "NOT_FOUND", "load: comment not found"},
+		{"unauthenticated", auth.ErrUnauthenticated, "UNAUTHENTICATED", auth.ErrUnauthenticated.Error()},
+		{"internal", errors.New(`pq: relation "posts" does not exist`), "INTERNAL_SERVER_ERROR", internalErrorMessage},
+		{"gqlgen", gqlerror.Errorf("time should be RFC3339Nano formatted string"), nil, "time should be RFC3339Nano formatted string"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := present(context.Background(), resolve(tc.err))
+			if got.Message != tc.wantMessage {
+				t.Fatalf("expected message %q, got %q", tc.wantMessage, got.Message)
+			}
+			if code := got.Extensions["code"]; code != tc.wantCode {
+				t.Fatalf("expected code %v, got %v", tc.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestErrorPresenter_ValidationFields(t *testing.T) {
+	present := NewErrorPresenter(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	err := validation.Errors{
+		{Field: "title", Message: "is required"},
+		{Field: "text", Message: "must be at most 2000 characters"},
+	}
+
+	got := present(context.Background(), err)
+	if got.Extensions["code"] != "VALIDATION_FAILED" {
+		t.Fatalf("expected VALIDATION_FAILED, got %v", got.Extensions["code"])
+	}
+	fields, ok := got.Extensions["fields"].([]map[string]any)
+	if !ok || len(fields) != 2 || fields[0]["field"] != "title" || fields[1]["message"] != "must be at most 2000 characters" {
+		t.Fatalf("unexpected fields: %#v", got.Extensions["fields"])
+	}
+}
+
+func TestRecoverFunc(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	err := NewRecoverFunc(log)(context.Background(), "boom")
+
+	got := NewErrorPresenter(log)(context.Background(), err)
+	if got.Message != internalErrorMessage || got.Extensions["code"] != "INTERNAL_SERVER_ERROR" {
+		t.Fatalf("expected a masked internal error, got %+v", got)
+	}
+}
@@ -0,0 +1,137 @@
+package graph
+
+import (
+	"context"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/limon4ik-black/graphql-comments-system.git/graph/model"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pagination"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Assumed sizes of lists whose length the client does not bound with an
+// argument, used to estimate the cost of a query.
+const (
+	assumedPosts     = 10
+	assumedComments  = 10
+	assumedReplies   = 5
+	assumedRevisions = 5
+)
+
+// SetComplexity fills in the cost of list fields: the cost of the selected
+// fields is multiplied by the number of items the list may return, taken
+// from limit/first/last when given. Fields that preload a comment tree with
+// maxDepth also pay for every preloaded level.
+func SetComplexity(c *ComplexityRoot) {
+	c.Query.Posts = func(child int) int {
+		return 1 + assumedPosts*child
+	}
+	c.Query.PostsConnection = func(child int, first *int32, _ *string, _ *model.PostFilter, _ *model.PostOrder) int {
+		return 1 + pageSize(first, nil)*child
+	}
+	c.Query.CommentThread = func(child int, _ string, maxDepth int32, _ *model.CommentSort) int {
+		return 1 + child + treeCost(assumedReplies, &maxDepth)
+	}
+	c.Post.Comments = func(child int, limit, _ *int32, maxDepth *int32, _ *model.CommentSort, _ *model.CommentFilter) int {
+		n := assumedComments
+		if limit != nil && *limit >= 0 {
+			n = int(*limit)
+		}
+		return 1 + n*child + treeCost(n, maxDepth)
+	}
+	c.Post.CommentsConnection = func(child int, first *int32, _ *string, last *int32, _ *string, _ *model.CommentFilter) int {
+		return 1 + pageSize(first, last)*child
+	}
+	c.Post.Revisions = func(child int) int {
+		return 1 + assumedRevisions*child
+	}
+	c.Comment.Children = func(child int, maxDepth *int32, _ *model.CommentSort) int {
+		return 1 + assumedReplies*child + treeCost(assumedReplies, maxDepth)
+	}
+}
+
+// pageSize is the number of edges a connection returns for first or last.
+func pageSize(first, last *int32) int {
+	n := pagination.DefaultPageSize
+	switch {
+	case first != nil:
+		n = int(*first)
+	case last != nil:
+		n = int(*last)
+	}
+	return max(0, min(n, pagination.MaxPageSize))
+}
+
+// treeCost is the cost of preloading maxDepth levels of replies below n
+// comments.
+func treeCost(n int, maxDepth *int32) int {
+	if maxDepth == nil {
+		return 0
+	}
+	cost := 0
+	for level := int32(1); level < *maxDepth && cost < 1<<20; level++ {
+		n *= assumedReplies
+		cost += n
+	}
+	return cost
+}
+
+const errDepthLimit = "DEPTH_LIMIT_EXCEEDED"
+
+// DepthLimit is a gqlgen extension rejecting operations that nest
+// selections deeper than Max fields. Introspection fields are not counted.
+type DepthLimit struct {
+	Max int
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = DepthLimit{}
+
+func (DepthLimit) ExtensionName() string { return "DepthLimit" }
+
+func (DepthLimit) Validate(graphql.ExecutableSchema) error { return nil }
+
+func (d DepthLimit) MutateOperationContext(ctx context.Context, oc *graphql.OperationContext) *gqlerror.Error {
+	op := oc.Doc.Operations.ForName(oc.OperationName)
+	if op == nil {
+		return nil
+	}
+	if depth := selectionDepth(op.SelectionSet, d.Max+1); depth > d.Max {
+		err := gqlerror.Errorf("operation exceeds the maximum selection depth of %d", d.Max)
+		errcode.Set(err, errDepthLimit)
+		return err
+	}
+	return nil
+}
+
+// selectionDepth returns the depth of the deepest field in set, counting
+// no further than limit.
+func selectionDepth(set ast.SelectionSet, limit int) int {
+	if limit <= 0 {
+		return 0
+	}
+	depth := 0
+	for _, sel := range set {
+		var d int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(sel.Name, "__") {
+				continue
+			}
+			d = 1 + selectionDepth(sel.SelectionSet, limit-1)
+		case *ast.InlineFragment:
+			d = selectionDepth(sel.SelectionSet, limit)
+		case *ast.FragmentSpread:
+			if sel.Definition != nil {
+				d = selectionDepth(sel.Definition.SelectionSet, limit)
+			}
+		}
+		depth = max(depth, d)
+	}
+	return depth
+}
@@ -0,0 +1,76 @@
+package graph
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func parseQuery(t *testing.T, es graphql.ExecutableSchema, query string) *ast.QueryDocument {
+	t.Helper()
+	doc, errs := gqlparser.LoadQuery(es.Schema(), query)
+	if errs != nil {
+		t.Fatalf("invalid query: %v", errs)
+	}
+	return doc
+}
+
+func TestDepthLimit(t *testing.T) {
+	es := NewExecutableSchema(Config{})
+	nested := "query { post(id: \"1\") { comments { " + strings.Repeat("children { ", 4) + "id" + strings.Repeat(" }", 4) + " } } }"
+
+	cases := []struct {
+		name  string
+		query string
+		max   int
+		ok    bool
+	}{
+		{"within limit", nested, 7, true},
+		{"too deep", nested, 6, false},
+		{"fragments count", "query { post(id: \"1\") { ...P } } fragment P on Post { comments { ... on Comment { children { id } } } }", 3, false},
+		{"introspection is free", "query { __schema { types { fields { type { ofType { ofType { name } } } } } } }", 1, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			oc := &graphql.OperationContext{Doc: parseQuery(t, es, tc.query)}
+			err := DepthLimit{Max: tc.max}.MutateOperationContext(context.Background(), oc)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tc.ok && (err == nil || err.Extensions["code"] != errDepthLimit) {
+				t.Fatalf("expected %s, got %v", errDepthLimit, err)
+			}
+		})
+	}
+}
+
+func TestSetComplexity(t *testing.T) {
+	cfg := Config{}
+	SetComplexity(&cfg.Complexity)
+	es := NewExecutableSchema(cfg)
+
+	calc := func(query string) int {
+		doc := parseQuery(t, es, query)
+		return complexity.Calculate(context.Background(), es, doc.Operations[0], nil)
+	}
+
+	// post: 1 + comments: 1 + 3 * (id: 1)
+	if got := calc(`query { post(id: "1") { comments(limit: 3) { id } } }`); got != 5 {
+		t.Fatalf("expected complexity 5, got %d", got)
+	}
+	small := calc(`query { post(id: "1") { commentsConnection(first: 5) { edges { node { id } } } } }`)
+	large := calc(`query { post(id: "1") { commentsConnection(first: 100) { edges { node { id } } } } }`)
+	if large <= small {
+		t.Fatalf("expected a larger page to cost more, got %d and %d", small, large)
+	}
+	shallow := calc(`query { post(id: "1") { comments(maxDepth: 1) { id } } }`)
+	deep := calc(`query { post(id: "1") { comments(maxDepth: 5) { id } } }`)
+	if deep <= shallow {
+		t.Fatalf("expected preloading more levels to cost more, got %d and %d", shallow, deep)
+	}
+}
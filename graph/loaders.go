@@ -0,0 +1,104 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/limon4ik-black/graphql-comments-system.git/graph/model"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/dataloader"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/service"
+)
+
+type loadersKey struct{}
+
+// commentsKey identifies the comments below a post or a comment. A zero
+// MaxDepth loads a single level without marking truncated replies.
+type commentsKey struct {
+	ID       string
+	MaxDepth int
+	Order    domain.CommentOrder
+}
+
+func newCommentsKey(id string, maxDepth *int32, sort model.CommentSort) (commentsKey, error) {
+	key := commentsKey{ID: id, Order: domain.CommentOrder(sort)}
+	if maxDepth != nil {
+		if *maxDepth < 1 {
+			return commentsKey{}, domain.Validation("maxDepth must be positive")
+		}
+		key.MaxDepth = int(*maxDepth)
+	}
+	return key, nil
+}
+
+// Loaders batch the lookups made while resolving a single response.
+type Loaders struct {
+	RootsByPostID     *dataloader.Loader[commentsKey, []*domain.Comment]
+	RepliesByParentID *dataloader.Loader[commentsKey, []*domain.Comment]
+	MyVoteByCommentID *dataloader.Loader[string, domain.VoteValue]
+	RevisionsByPostID *dataloader.Loader[string, []*domain.PostRevision]
+}
+
+func NewLoaders(posts *service.PostService, comments *service.CommentService) *Loaders {
+	return &Loaders{
+		RootsByPostID: dataloader.New(grouped(func(ctx context.Context, ids []string, maxDepth int, order domain.CommentOrder) (map[string][]*domain.Comment, error) {
+			if maxDepth == 0 {
+				return comments.GetRootsByPostIDs(ctx, ids, order)
+			}
+			return comments.GetRootTreesByPostIDs(ctx, ids, maxDepth, order)
+		})),
+		RepliesByParentID: dataloader.New(grouped(func(ctx context.Context, ids []string, maxDepth int, order domain.CommentOrder) (map[string][]*domain.Comment, error) {
+			if maxDepth == 0 {
+				return comments.GetChildrenByParentIDs(ctx, ids, order)
+			}
+			return comments.GetRepliesByParentIDs(ctx, ids, maxDepth, order)
+		})),
+		MyVoteByCommentID: dataloader.New(comments.GetMyVotes),
+		RevisionsByPostID: dataloader.New(posts.GetRevisionsByPostIDs),
+	}
+}
+
+// grouped turns a comments lookup into a batch function that makes one
+// call per distinct depth and order in the batch.
+func grouped(fetch func(ctx context.Context, ids []string, maxDepth int, order domain.CommentOrder) (map[string][]*domain.Comment, error)) dataloader.BatchFunc[commentsKey, []*domain.Comment] {
+	return func(ctx context.Context, keys []commentsKey) (map[commentsKey][]*domain.Comment, error) {
+		type group struct {
+			maxDepth int
+			order    domain.CommentOrder
+		}
+		idsByGroup := make(map[group][]string)
+		for _, k := range keys {
+			g := group{maxDepth: k.MaxDepth, order: k.Order}
+			idsByGroup[g] = append(idsByGroup[g], k.ID)
+		}
+
+		res := make(map[commentsKey][]*domain.Comment, len(keys))
+		for g, ids := range idsByGroup {
+			comments, err := fetch(ctx, ids, g.maxDepth, g.order)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range ids {
+				res[commentsKey{ID: id, MaxDepth: g.maxDepth, Order: g.order}] = comments[id]
+			}
+		}
+		return res, nil
+	}
+}
+
+// LoadersMiddleware gives every response its own loaders, so subscription
+// events never see results cached while resolving an earlier event.
+func LoadersMiddleware(posts *service.PostService, comments *service.CommentService) graphql.ResponseMiddleware {
+	return func(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+		return next(context.WithValue(ctx, loadersKey{}, NewLoaders(posts, comments)))
+	}
+}
+
+func loadersFor(ctx context.Context) (*Loaders, error) {
+	loaders, ok := ctx.Value(loadersKey{}).(*Loaders)
+	if !ok {
+		return nil, &internalError{err: errors.New("loaders are missing from the context")}
+	}
+	return loaders, nil
+}
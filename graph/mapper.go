@@ -0,0 +1,219 @@
+package graph
+
+import (
+	"github.com/limon4ik-black/graphql-comments-system.git/graph/model"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/events"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pagination"
+)
+
+func mapPostToModel(post *domain.Post) *model.Post {
+	if post == nil {
+		return nil
+	}
+
+	return &model.Post{
+		ID:              post.ID,
+		Title:           post.Title,
+		Content:         post.Content,
+		Author:          post.Author,
+		CommentsAllowed: post.Flag,
+		Revision:        int32(post.Revision),
+		CreatedAt:       post.CreatedAt,
+		UpdatedAt:       post.UpdatedAt,
+	}
+}
+
+func mapRevisionsToModel(revisions []*domain.PostRevision) []*model.PostRevision {
+	res := make([]*model.PostRevision, 0, len(revisions))
+	for _, rev := range revisions {
+		res = append(res, &model.PostRevision{
+			Revision:  int32(rev.Revision),
+			Title:     rev.Title,
+			Content:   rev.Content,
+			CreatedAt: rev.CreatedAt,
+		})
+	}
+	return res
+}
+
+func mapCommentToModel(c *domain.Comment) *model.Comment {
+	if c == nil {
+		return nil
+	}
+
+	m := &model.Comment{
+		ID:             c.ID,
+		PostID:         c.PostID,
+		ParentID:       c.ParentID,
+		Author:         c.Author,
+		Text:           c.Text,
+		CreatedAt:      c.CreatedAt,
+		UpdatedAt:      c.UpdatedAt,
+		Edited:         c.Edited,
+		Deleted:        c.DeletedAt != nil,
+		Depth:          int32(c.Depth),
+		Locked:         c.Locked,
+		Score:          int32(c.Upvotes - c.Downvotes),
+		Upvotes:        int32(c.Upvotes),
+		Downvotes:      int32(c.Downvotes),
+		ReplyCount:     int32(c.ReplyCount),
+		HasMoreReplies: c.HasMoreReplies,
+	}
+	if c.Children != nil {
+		m.Replies = mapCommentsToModel(c.Children)
+	}
+	return m
+}
+
+func mapCommentsToModel(comments []*domain.Comment) []*model.Comment {
+	res := make([]*model.Comment, 0, len(comments))
+	for _, c := range comments {
+		res = append(res, mapCommentToModel(c))
+	}
+	return res
+}
+
+// mapCommentTreeToModel maps comments loaded in the given sort order,
+// remembering it so that their children are loaded the same way.
+func mapCommentTreeToModel(comments []*domain.Comment, sort model.CommentSort) []*model.Comment {
+	res := mapCommentsToModel(comments)
+	setSort(res, sort)
+	return res
+}
+
+func setSort(comments []*model.Comment, sort model.CommentSort) {
+	for _, c := range comments {
+		c.Sort = sort
+		setSort(c.Replies, sort)
+	}
+}
+
+func mapVoteToModel(v domain.VoteValue) model.VoteValue {
+	switch v {
+	case domain.VoteUp:
+		return model.VoteValueUp
+	case domain.VoteDown:
+		return model.VoteValueDown
+	default:
+		return model.VoteValueNone
+	}
+}
+
+func mapVoteToDomain(v model.VoteValue) domain.VoteValue {
+	switch v {
+	case model.VoteValueUp:
+		return domain.VoteUp
+	case model.VoteValueDown:
+		return domain.VoteDown
+	default:
+		return domain.VoteNone
+	}
+}
+
+func mapCommentPageToModel(page pagination.Page[*domain.Comment]) *model.CommentConnection {
+	conn := &model.CommentConnection{
+		Edges: make([]*model.CommentEdge, 0, len(page.Items)),
+		PageInfo: &model.PageInfo{
+			HasNextPage:     page.HasNextPage,
+			HasPreviousPage: page.HasPreviousPage,
+		},
+	}
+
+	for _, c := range page.Items {
+		conn.Edges = append(conn.Edges, &model.CommentEdge{
+			Cursor: pagination.Cursor{CreatedAt: c.CreatedAt, ID: c.ID}.Encode(),
+			Node:   mapCommentToModel(c),
+		})
+	}
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.StartCursor = &conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+
+	return conn
+}
+
+func mapPostPageToModel(page pagination.Page[*domain.Post]) *model.PostConnection {
+	conn := &model.PostConnection{
+		Edges: make([]*model.PostEdge, 0, len(page.Items)),
+		PageInfo: &model.PageInfo{
+			HasNextPage:     page.HasNextPage,
+			HasPreviousPage: page.HasPreviousPage,
+		},
+	}
+
+	for _, p := range page.Items {
+		conn.Edges = append(conn.Edges, &model.PostEdge{
+			Cursor: pagination.Cursor{CreatedAt: p.CreatedAt, ID: p.ID}.Encode(),
+			Node:   mapPostToModel(p),
+		})
+	}
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.StartCursor = &conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+
+	return conn
+}
+
+func mapPostFilterToDomain(f *model.PostFilter) domain.PostFilter {
+	if f == nil {
+		return domain.PostFilter{}
+	}
+
+	return domain.PostFilter{
+		Author:          f.Author,
+		CommentsAllowed: f.CommentsAllowed,
+		CreatedAfter:    f.CreatedAfter,
+		CreatedBefore:   f.CreatedBefore,
+	}
+}
+
+func sortOrDefault(sort *model.CommentSort, def model.CommentSort) model.CommentSort {
+	if sort == nil {
+		return def
+	}
+	return *sort
+}
+
+func intPtr(v *int32) *int {
+	if v == nil {
+		return nil
+	}
+	i := int(*v)
+	return &i
+}
+
+func mapCommentFilterToDomain(f *model.CommentFilter) domain.CommentFilter {
+	if f == nil {
+		return domain.CommentFilter{}
+	}
+
+	return domain.CommentFilter{
+		CreatedAfter:  f.CreatedAfter,
+		CreatedBefore: f.CreatedBefore,
+	}
+}
+
+func mapEventToModel(e events.Event) model.PostEvent {
+	seq := int32(e.Seq)
+	switch e.Type {
+	case events.CommentAdded:
+		return &model.CommentAdded{Seq: seq, Comment: mapCommentToModel(e.Comment)}
+	case events.CommentEdited:
+		return &model.CommentEdited{Seq: seq, Comment: mapCommentToModel(e.Comment)}
+	case events.CommentVoted:
+		return &model.CommentVoted{Seq: seq, Comment: mapCommentToModel(e.Comment)}
+	case events.CommentLocked:
+		return &model.CommentLocked{Seq: seq, Comment: mapCommentToModel(e.Comment)}
+	case events.CommentDeleted:
+		return &model.CommentDeleted{Seq: seq, CommentID: e.CommentID, Tombstone: mapCommentToModel(e.Comment)}
+	case events.CommentsToggled:
+		return &model.CommentsToggled{Seq: seq, PostID: e.PostID, Allowed: e.CommentsAllowed}
+	case events.PostUpdated:
+		return &model.PostUpdated{Seq: seq, Post: mapPostToModel(e.Post)}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+type Comment struct {
+	ID             string    `json:"id"`
+	PostID         string    `json:"postID"`
+	ParentID       *string   `json:"parentID,omitempty"`
+	Author         string    `json:"author"`
+	Text           string    `json:"text"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	Edited         bool      `json:"edited"`
+	Deleted        bool      `json:"deleted"`
+	Depth          int32     `json:"depth"`
+	Locked         bool      `json:"locked"`
+	Score          int32     `json:"score"`
+	Upvotes        int32     `json:"upvotes"`
+	Downvotes      int32     `json:"downvotes"`
+	ReplyCount     int32     `json:"replyCount"`
+	HasMoreReplies bool      `json:"hasMoreReplies"`
+	Seq            *int32    `json:"seq,omitempty"`
+	// Replies holds children already loaded with a depth-limited tree;
+	// nil means they are resolved on demand.
+	Replies []*Comment `json:"-"`
+	// Sort is the order the comment was loaded in, inherited by its
+	// children.
+	Sort CommentSort `json:"-"`
+}
@@ -2,29 +2,368 @@
 
 package model
 
-type Comment struct {
-	ID       string     `json:"id"`
-	PostID   string     `json:"postID"`
-	ParentID *string    `json:"parentID,omitempty"`
-	Author   string     `json:"author"`
-	Text     string     `json:"text"`
-	Children []*Comment `json:"children"`
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Events of a post are numbered by seq, starting from 1.
+type PostEvent interface {
+	IsPostEvent()
+}
+
+type CommentAdded struct {
+	Seq     int32    `json:"seq"`
+	Comment *Comment `json:"comment"`
+}
+
+func (CommentAdded) IsPostEvent() {}
+
+type CommentConnection struct {
+	Edges    []*CommentEdge `json:"edges"`
+	PageInfo *PageInfo      `json:"pageInfo"`
+}
+
+type CommentDeleted struct {
+	Seq       int32  `json:"seq"`
+	CommentID string `json:"commentID"`
+	// The tombstone left in place of a comment with replies, null if the comment was removed.
+	Tombstone *Comment `json:"tombstone,omitempty"`
+}
+
+func (CommentDeleted) IsPostEvent() {}
+
+type CommentEdge struct {
+	Cursor string   `json:"cursor"`
+	Node   *Comment `json:"node"`
 }
 
+// The text of a comment was edited.
+type CommentEdited struct {
+	Seq     int32    `json:"seq"`
+	Comment *Comment `json:"comment"`
+}
+
+func (CommentEdited) IsPostEvent() {}
+
+type CommentFilter struct {
+	CreatedAfter  *time.Time `json:"createdAfter,omitempty"`
+	CreatedBefore *time.Time `json:"createdBefore,omitempty"`
+}
+
+// A comment was locked or unlocked for replies.
+type CommentLocked struct {
+	Seq     int32    `json:"seq"`
+	Comment *Comment `json:"comment"`
+}
+
+func (CommentLocked) IsPostEvent() {}
+
+// A comment was voted on; comment carries the new score.
+type CommentVoted struct {
+	Seq     int32    `json:"seq"`
+	Comment *Comment `json:"comment"`
+}
+
+func (CommentVoted) IsPostEvent() {}
+
+type CommentsToggled struct {
+	Seq     int32  `json:"seq"`
+	PostID  string `json:"postID"`
+	Allowed bool   `json:"allowed"`
+}
+
+func (CommentsToggled) IsPostEvent() {}
+
 type Mutation struct {
 }
 
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor,omitempty"`
+	EndCursor       *string `json:"endCursor,omitempty"`
+}
+
 type Post struct {
-	ID              string     `json:"id"`
-	Title           string     `json:"title"`
-	Content         string     `json:"content"`
-	Author          string     `json:"author"`
-	CommentsAllowed bool       `json:"commentsAllowed"`
-	Comments        []*Comment `json:"comments"`
+	ID              string    `json:"id"`
+	Title           string    `json:"title"`
+	Content         string    `json:"content"`
+	Author          string    `json:"author"`
+	CommentsAllowed bool      `json:"commentsAllowed"`
+	Revision        int32     `json:"revision"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
 }
 
+type PostConnection struct {
+	Edges    []*PostEdge `json:"edges"`
+	PageInfo *PageInfo   `json:"pageInfo"`
+}
+
+type PostEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Post  `json:"node"`
+}
+
+type PostFilter struct {
+	Author          *string    `json:"author,omitempty"`
+	CommentsAllowed *bool      `json:"commentsAllowed,omitempty"`
+	CreatedAfter    *time.Time `json:"createdAfter,omitempty"`
+	CreatedBefore   *time.Time `json:"createdBefore,omitempty"`
+}
+
+type PostRevision struct {
+	Revision  int32     `json:"revision"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type PostUpdated struct {
+	Seq  int32 `json:"seq"`
+	Post *Post `json:"post"`
+}
+
+func (PostUpdated) IsPostEvent() {}
+
 type Query struct {
 }
 
+// Subscriptions given since first replay the events numbered above it, so a
+// client can reconnect without missing any. A subscriber that falls too far
+// behind to be caught up gets a SUBSCRIPTION_LAGGED error and the
+// subscription ends.
 type Subscription struct {
 }
+
+type CommentSort string
+
+const (
+	CommentSortNew           CommentSort = "NEW"
+	CommentSortOld           CommentSort = "OLD"
+	CommentSortTop           CommentSort = "TOP"
+	CommentSortBest          CommentSort = "BEST"
+	CommentSortControversial CommentSort = "CONTROVERSIAL"
+)
+
+var AllCommentSort = []CommentSort{
+	CommentSortNew,
+	CommentSortOld,
+	CommentSortTop,
+	CommentSortBest,
+	CommentSortControversial,
+}
+
+func (e CommentSort) IsValid() bool {
+	switch e {
+	case CommentSortNew, CommentSortOld, CommentSortTop, CommentSortBest, CommentSortControversial:
+		return true
+	}
+	return false
+}
+
+func (e CommentSort) String() string {
+	return string(e)
+}
+
+func (e *CommentSort) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CommentSort(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CommentSort", str)
+	}
+	return nil
+}
+
+func (e CommentSort) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *CommentSort) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e CommentSort) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type PostOrder string
+
+const (
+	PostOrderNewest PostOrder = "NEWEST"
+	PostOrderOldest PostOrder = "OLDEST"
+)
+
+var AllPostOrder = []PostOrder{
+	PostOrderNewest,
+	PostOrderOldest,
+}
+
+func (e PostOrder) IsValid() bool {
+	switch e {
+	case PostOrderNewest, PostOrderOldest:
+		return true
+	}
+	return false
+}
+
+func (e PostOrder) String() string {
+	return string(e)
+}
+
+func (e *PostOrder) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostOrder(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostOrder", str)
+	}
+	return nil
+}
+
+func (e PostOrder) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *PostOrder) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e PostOrder) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type Role string
+
+const (
+	RoleModerator Role = "MODERATOR"
+	RoleAdmin     Role = "ADMIN"
+)
+
+var AllRole = []Role{
+	RoleModerator,
+	RoleAdmin,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleModerator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *Role) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e Role) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type VoteValue string
+
+const (
+	VoteValueUp   VoteValue = "UP"
+	VoteValueDown VoteValue = "DOWN"
+	VoteValueNone VoteValue = "NONE"
+)
+
+var AllVoteValue = []VoteValue{
+	VoteValueUp,
+	VoteValueDown,
+	VoteValueNone,
+}
+
+func (e VoteValue) IsValid() bool {
+	switch e {
+	case VoteValueUp, VoteValueDown, VoteValueNone:
+		return true
+	}
+	return false
+}
+
+func (e VoteValue) String() string {
+	return string(e)
+}
+
+func (e *VoteValue) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = VoteValue(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid VoteValue", str)
+	}
+	return nil
+}
+
+func (e VoteValue) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *VoteValue) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e VoteValue) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
@@ -1,11 +1,13 @@
 package graph
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log/slog"
 	"sync"
 
-	"github.com/limon4ik-black/graphql-comments-system.git/graph/model"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/events"
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/service"
 	"github.com/redis/go-redis/v9"
 )
@@ -16,11 +18,54 @@ import (
 // here.
 
 type Resolver struct {
-	PostService        *service.PostService
-	CommentService     *service.CommentService
-	DB                 *sql.DB
-	Redis              *redis.Client
-	CommentSubscribers map[string][]chan *model.Comment
-	Mu                 sync.Mutex
-	Log                *slog.Logger
+	PostService    *service.PostService
+	CommentService *service.CommentService
+	DB             *sql.DB
+	Redis          *redis.Client
+	Events         *events.Bus
+	Log            *slog.Logger
+
+	// subscriptions counts the subscription goroutines that are still
+	// delivering events. Once closing is set no new ones are counted, so
+	// WaitSubscriptions never races a subscription that is just starting.
+	subscriptionsMu sync.Mutex
+	closing         bool
+	subscriptions   sync.WaitGroup
+}
+
+// errShuttingDown rejects subscriptions started after shutdown began.
+var errShuttingDown = errors.New("server is shutting down")
+
+// trackSubscription counts a new subscription, reporting false once
+// shutdown has begun.
+func (r *Resolver) trackSubscription() bool {
+	r.subscriptionsMu.Lock()
+	defer r.subscriptionsMu.Unlock()
+	if r.closing {
+		return false
+	}
+	r.subscriptions.Add(1)
+	return true
+}
+
+// WaitSubscriptions blocks until every subscription has ended, which
+// happens once their websocket connections are closed, or until ctx is
+// done. Subscriptions started afterwards are rejected.
+func (r *Resolver) WaitSubscriptions(ctx context.Context) error {
+	r.subscriptionsMu.Lock()
+	r.closing = true
+	r.subscriptionsMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.subscriptions.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
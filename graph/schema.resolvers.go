@@ -9,30 +9,91 @@ import (
 	"context"
 
 	"github.com/limon4ik-black/graphql-comments-system.git/graph/model"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/auth"
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/events"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pagination"
 )
 
+// MyVote is the resolver for the myVote field.
+func (r *commentResolver) MyVote(ctx context.Context, obj *model.Comment) (model.VoteValue, error) {
+	loaders, err := loadersFor(ctx)
+	if err != nil {
+		return model.VoteValueNone, err
+	}
+	vote, err := loaders.MyVoteByCommentID.Load(ctx, obj.ID)
+	if err != nil {
+		return model.VoteValueNone, err
+	}
+
+	return mapVoteToModel(vote), nil
+}
+
+// Children is the resolver for the children field.
+func (r *commentResolver) Children(ctx context.Context, obj *model.Comment, maxDepth *int32, sort *model.CommentSort) ([]*model.Comment, error) {
+	order := sortOrDefault(sort, obj.Sort)
+	if maxDepth == nil && obj.Replies != nil && order == obj.Sort {
+		return obj.Replies, nil
+	}
+
+	key, err := newCommentsKey(obj.ID, maxDepth, order)
+	if err != nil {
+		return nil, err
+	}
+	loaders, err := loadersFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	children, err := loaders.RepliesByParentID.Load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapCommentTreeToModel(children, order), nil
+}
+
 // CreatePost is the resolver for the createPost field.
-func (r *mutationResolver) CreatePost(ctx context.Context, title string, content string, author string) (*model.Post, error) {
+func (r *mutationResolver) CreatePost(ctx context.Context, title string, content string) (*model.Post, error) {
 	r.Log.Info("CreatePost called", "title", title)
+	principal, err := auth.RequirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	post := &domain.Post{
 		Title:   title,
 		Content: content,
-		Author:  author,
+		Author:  principal.Subject,
 	}
 
-	err := r.PostService.Create(ctx, post)
+	err = r.PostService.Create(ctx, post)
 	if err != nil {
 		return nil, err
 	}
 	r.Log.Info("CreatePost complete", "postId", post.ID)
-	return &model.Post{
-		ID:              post.ID,
-		Title:           post.Title,
-		Content:         post.Content,
-		Author:          post.Author,
-		CommentsAllowed: post.Flag,
-	}, nil
+	return mapPostToModel(post), nil
+}
+
+// UpdatePost is the resolver for the updatePost field.
+func (r *mutationResolver) UpdatePost(ctx context.Context, id string, title string, content string) (*model.Post, error) {
+	r.Log.Info("UpdatePost called", "postID", id)
+	post, err := r.PostService.Update(ctx, id, title, content)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapPostToModel(post), nil
+}
+
+// RevertPost is the resolver for the revertPost field.
+func (r *mutationResolver) RevertPost(ctx context.Context, id string, revision int32) (*model.Post, error) {
+	r.Log.Info("RevertPost called", "postID", id, "revision", revision)
+	post, err := r.PostService.Revert(ctx, id, int(revision))
+	if err != nil {
+		return nil, err
+	}
+
+	return mapPostToModel(post), nil
 }
 
 // ToggleComments is the resolver for the toggleComments field.
@@ -47,80 +108,135 @@ func (r *mutationResolver) ToggleComments(ctx context.Context, postID string, al
 		return nil, err
 	}
 
-	return mapPostDomainToModel(post), nil
+	return mapPostToModel(post), nil
 }
 
-func mapPostDomainToModel(p *domain.Post) *model.Post {
-	if p == nil {
-		return nil
+// AddComment is the resolver for the addComment field.
+func (r *mutationResolver) AddComment(ctx context.Context, postID string, parentID *string, text string) (*model.Comment, error) {
+	r.Log.Info("AddComment called", "postID", postID)
+	principal, err := auth.RequirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := &domain.Comment{
+		PostID:   postID,
+		ParentID: parentID,
+		Text:     text,
+		Author:   principal.Subject,
 	}
-	return &model.Post{
-		ID:              p.ID,
-		Title:           p.Title,
-		Content:         p.Content,
-		Author:          p.Author,
-		CommentsAllowed: p.Flag,
-		Comments:        mapCommentsDomainToModel(p.Comments),
+
+	err = r.CommentService.Create(ctx, comment)
+	if err != nil {
+		return nil, err
 	}
+
+	return mapCommentToModel(comment), nil
 }
 
-func mapCommentDomainToModel(c *domain.Comment) *model.Comment {
-	if c == nil {
-		return nil
+// EditComment is the resolver for the editComment field.
+func (r *mutationResolver) EditComment(ctx context.Context, id string, text string) (*model.Comment, error) {
+	r.Log.Info("EditComment called", "commentID", id)
+	comment, err := r.CommentService.Edit(ctx, id, text)
+	if err != nil {
+		return nil, err
 	}
-	return &model.Comment{
-		ID:       c.ID,
-		PostID:   c.PostID,
-		ParentID: c.ParentID,
-		Author:   c.Author,
-		Text:     c.Text,
-		Children: mapCommentsDomainToModel(c.Children),
+
+	return mapCommentToModel(comment), nil
+}
+
+// DeleteComment is the resolver for the deleteComment field.
+func (r *mutationResolver) DeleteComment(ctx context.Context, id string) (bool, error) {
+	r.Log.Info("DeleteComment called", "commentID", id)
+	if err := r.CommentService.Delete(ctx, id); err != nil {
+		return false, err
 	}
+
+	return true, nil
 }
 
-func mapCommentsDomainToModel(comments []*domain.Comment) []*model.Comment {
-	res := make([]*model.Comment, 0, len(comments))
-	for _, c := range comments {
-		res = append(res, mapCommentDomainToModel(c))
+// VoteComment is the resolver for the voteComment field.
+func (r *mutationResolver) VoteComment(ctx context.Context, id string, value model.VoteValue) (*model.Comment, error) {
+	r.Log.Info("VoteComment called", "commentID", id, "value", value)
+	comment, err := r.CommentService.Vote(ctx, id, mapVoteToDomain(value))
+	if err != nil {
+		return nil, err
 	}
-	return res
+
+	return mapCommentToModel(comment), nil
 }
 
-// AddComment is the resolver for the addComment field.
-func (r *mutationResolver) AddComment(ctx context.Context, postID string, parentID *string, text string) (*model.Comment, error) {
-	r.Log.Info("AddComment called", "postID", postID)
-	comment := &domain.Comment{
-		PostID:   postID,
-		ParentID: parentID,
-		Text:     text,
-		Author:   "anonymous",
+// LockComment is the resolver for the lockComment field.
+func (r *mutationResolver) LockComment(ctx context.Context, id string, locked bool) (*model.Comment, error) {
+	r.Log.Info("LockComment called", "commentID", id, "locked", locked)
+	comment, err := r.CommentService.Lock(ctx, id, locked)
+	if err != nil {
+		return nil, err
 	}
 
-	err := r.CommentService.Create(ctx, comment)
+	return mapCommentToModel(comment), nil
+}
+
+// Revisions is the resolver for the revisions field.
+func (r *postResolver) Revisions(ctx context.Context, obj *model.Post) ([]*model.PostRevision, error) {
+	loaders, err := loadersFor(ctx)
 	if err != nil {
 		return nil, err
 	}
+	revisions, err := loaders.RevisionsByPostID.Load(ctx, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapRevisionsToModel(revisions), nil
+}
 
-	modelComment := &model.Comment{
-		ID:       comment.ID,
-		PostID:   comment.PostID,
-		ParentID: comment.ParentID,
-		Author:   comment.Author,
-		Text:     comment.Text,
+// Comments is the resolver for the comments field.
+func (r *postResolver) Comments(ctx context.Context, obj *model.Post, limit *int32, offset *int32, maxDepth *int32, sort *model.CommentSort, filter *model.CommentFilter) ([]*model.Comment, error) {
+	order := sortOrDefault(sort, model.CommentSortOld)
+	// Unfiltered full listings are batched across posts.
+	if limit == nil && offset == nil && filter == nil {
+		key, err := newCommentsKey(obj.ID, maxDepth, order)
+		if err != nil {
+			return nil, err
+		}
+		loaders, err := loadersFor(ctx)
+		if err != nil {
+			return nil, err
+		}
+		comments, err := loaders.RootsByPostID.Load(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		return mapCommentTreeToModel(comments, order), nil
 	}
 
-	r.Mu.Lock()
-	subs := r.CommentSubscribers[postID]
-	for _, ch := range subs {
-		select {
-		case ch <- modelComment:
-		default:
+	comments, err := r.CommentService.GetRootsByPostID(ctx, obj.ID, mapCommentFilterToDomain(filter), domain.CommentOrder(order), intPtr(limit), intPtr(offset))
+	if err != nil {
+		return nil, err
+	}
+	if maxDepth != nil {
+		if err := r.CommentService.ExpandReplies(ctx, comments, int(*maxDepth), domain.CommentOrder(order)); err != nil {
+			return nil, err
 		}
 	}
-	r.Mu.Unlock()
-	r.Log.Info("Notifying subscribers", "postID", postID, "count", len(subs))
 
-	return modelComment, nil
+	return mapCommentTreeToModel(comments, order), nil
+}
+
+// CommentsConnection is the resolver for the commentsConnection field.
+func (r *postResolver) CommentsConnection(ctx context.Context, obj *model.Post, first *int32, after *string, last *int32, before *string, filter *model.CommentFilter) (*model.CommentConnection, error) {
+	page, err := r.CommentService.GetRootsPage(ctx, obj.ID, mapCommentFilterToDomain(filter), pagination.Args{
+		First:  intPtr(first),
+		After:  after,
+		Last:   intPtr(last),
+		Before: before,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mapCommentPageToModel(page), nil
 }
 
 // Posts is the resolver for the posts field.
@@ -139,6 +255,25 @@ func (r *queryResolver) Posts(ctx context.Context) ([]*model.Post, error) {
 	return result, nil
 }
 
+// PostsConnection is the resolver for the postsConnection field.
+func (r *queryResolver) PostsConnection(ctx context.Context, first *int32, after *string, filter *model.PostFilter, orderBy *model.PostOrder) (*model.PostConnection, error) {
+	r.Log.Info("PostsConnection called")
+	var order domain.PostOrder
+	if orderBy != nil {
+		order = domain.PostOrder(*orderBy)
+	}
+
+	page, err := r.PostService.GetPage(ctx, mapPostFilterToDomain(filter), order, pagination.Args{
+		First: intPtr(first),
+		After: after,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mapPostPageToModel(page), nil
+}
+
 // Post is the resolver for the post field.
 func (r *queryResolver) Post(ctx context.Context, id string) (*model.Post, error) {
 	r.Log.Info("Post called", "postID", id) //postId
@@ -150,75 +285,57 @@ func (r *queryResolver) Post(ctx context.Context, id string) (*model.Post, error
 	return mapPostToModel(post), nil
 }
 
+// CommentThread is the resolver for the commentThread field.
+func (r *queryResolver) CommentThread(ctx context.Context, id string, maxDepth int32, sort *model.CommentSort) (*model.Comment, error) {
+	r.Log.Info("CommentThread called", "commentID", id)
+	order := sortOrDefault(sort, model.CommentSortOld)
+	comment, err := r.CommentService.GetThread(ctx, id, int(maxDepth), domain.CommentOrder(order))
+	if err != nil {
+		return nil, err
+	}
+
+	return mapCommentTreeToModel([]*domain.Comment{comment}, order)[0], nil
+}
+
 // CommentAdded is the resolver for the commentAdded field.
-func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string) (<-chan *model.Comment, error) {
+func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string, since *int32) (<-chan *model.Comment, error) {
 	r.Log.Info("CommentAdded called", "postID", postID)
-	ch := make(chan *model.Comment, 10)
-
-	r.Mu.Lock()
-	r.CommentSubscribers[postID] = append(r.CommentSubscribers[postID], ch)
-	r.Log.Info("Added subscription", "postID", postID, "total", len(r.CommentSubscribers[postID]))
-	r.Mu.Unlock()
-
-	go func() {
-		<-ctx.Done()
-		r.Log.Info("Subscription cancelled", "postID", postID)
-		r.Mu.Lock()
-		subs := r.CommentSubscribers[postID]
-		for i, sub := range subs {
-			if sub == ch {
-				r.CommentSubscribers[postID] = append(subs[:i], subs[i+1:]...)
-				break
-			}
+	return subscribe(ctx, r.Resolver, postID, since, func(e events.Event) *model.Comment {
+		if e.Type != events.CommentAdded {
+			return nil
 		}
-		r.Log.Info("Subscription removed", "postID", postID, "total", len(r.CommentSubscribers[postID]))
-		r.Mu.Unlock()
-	}()
-
-	return ch, nil
+		comment := mapCommentToModel(e.Comment)
+		if e.Seq != 0 {
+			seq := int32(e.Seq)
+			comment.Seq = &seq
+		}
+		return comment
+	})
 }
 
-func mapPostToModel(post *domain.Post) *model.Post {
-	var comments []*model.Comment
-	for _, c := range post.Comments {
-		comments = append(comments, mapCommentToModel(c))
-	}
-
-	return &model.Post{
-		ID:              post.ID,
-		Title:           post.Title,
-		Content:         post.Content,
-		Author:          post.Author,
-		CommentsAllowed: post.Flag,
-		Comments:        comments,
-	}
+// PostEvents is the resolver for the postEvents field.
+func (r *subscriptionResolver) PostEvents(ctx context.Context, postID string, since *int32) (<-chan model.PostEvent, error) {
+	r.Log.Info("PostEvents called", "postID", postID)
+	return subscribe(ctx, r.Resolver, postID, since, mapEventToModel)
 }
 
-func mapCommentToModel(c *domain.Comment) *model.Comment {
-	var children []*model.Comment
-	for _, child := range c.Children {
-		children = append(children, mapCommentToModel(child))
-	}
-
-	return &model.Comment{
-		ID:       c.ID,
-		PostID:   c.PostID,
-		ParentID: c.ParentID,
-		Author:   c.Author,
-		Text:     c.Text,
-		Children: children,
-	}
-}
+// Comment returns CommentResolver implementation.
+func (r *Resolver) Comment() CommentResolver { return &commentResolver{r} }
 
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
+// Post returns PostResolver implementation.
+func (r *Resolver) Post() PostResolver { return &postResolver{r} }
+
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
 // Subscription returns SubscriptionResolver implementation.
 func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
 
+type commentResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
+type postResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
 type subscriptionResolver struct{ *Resolver }
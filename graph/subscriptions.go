@@ -0,0 +1,121 @@
+package graph
+
+import (
+	"context"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/events"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/metrics"
+)
+
+// subscribe delivers the events of a post, as mapped by mapEvent, until
+// ctx is done. Events mapped to nil are skipped. A subscriber that lagged
+// behind gets events.ErrLagged as the last response.
+func subscribe[T comparable](ctx context.Context, r *Resolver, postID string, since *int32, mapEvent func(events.Event) T) (<-chan T, error) {
+	var from *int64
+	if since != nil {
+		if *since < 0 {
+			return nil, domain.Validation("since must not be negative")
+		}
+		n := int64(*since)
+		from = &n
+	}
+
+	if !r.trackSubscription() {
+		return nil, errShuttingDown
+	}
+	evs, err := r.Events.Subscribe(ctx, postID, from)
+	if err != nil {
+		r.subscriptions.Done()
+		return nil, err
+	}
+
+	metrics.SubscriberAdded(postID)
+	ch := make(chan T)
+	go func() {
+		defer r.subscriptions.Done()
+		defer close(ch)
+		defer metrics.SubscriberRemoved(postID)
+		var none T
+		for e := range evs {
+			if e.Type == events.Lagged {
+				r.Log.Warn("Subscriber lagged", "postID", postID, "seq", e.Seq)
+				endStream(ctx, events.ErrLagged)
+				return
+			}
+			v := mapEvent(e)
+			if v == none {
+				continue
+			}
+			select {
+			case ch <- v:
+			case <-ctx.Done():
+			}
+		}
+		r.Log.Info("Subscription cancelled", "postID", postID)
+	}()
+
+	return ch, nil
+}
+
+type streamEndKey struct{}
+
+// streamEnd holds the error a subscription stream ended with until it is
+// reported.
+type streamEnd struct {
+	mu  sync.Mutex
+	err error
+}
+
+// endStream records err to be reported once the subscription of ctx has
+// delivered its last event.
+func endStream(ctx context.Context, err error) {
+	if end, ok := ctx.Value(streamEndKey{}).(*streamEnd); ok {
+		end.mu.Lock()
+		end.err = err
+		end.mu.Unlock()
+	}
+}
+
+// StreamErrors reports the error a subscription ended with, recorded by
+// endStream, as a final response. Without it gqlgen can only end a stream
+// silently.
+type StreamErrors struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+	graphql.ResponseInterceptor
+} = StreamErrors{}
+
+func (StreamErrors) ExtensionName() string { return "StreamErrors" }
+
+func (StreamErrors) Validate(graphql.ExecutableSchema) error { return nil }
+
+func (StreamErrors) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	return next(context.WithValue(ctx, streamEndKey{}, &streamEnd{}))
+}
+
+func (StreamErrors) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	resp := next(ctx)
+	if resp != nil {
+		return resp
+	}
+
+	end, ok := ctx.Value(streamEndKey{}).(*streamEnd)
+	if !ok {
+		return nil
+	}
+	end.mu.Lock()
+	err := end.err
+	end.err = nil
+	end.mu.Unlock()
+	if err == nil {
+		return nil
+	}
+
+	graphql.AddError(ctx, err)
+	return &graphql.Response{Errors: graphql.GetErrors(ctx)}
+}
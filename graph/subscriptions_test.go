@@ -0,0 +1,62 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/limon4ik-black/graphql-comments-system.git/graph/model"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/events"
+)
+
+func TestSubscribe_RejectedAfterShutdown(t *testing.T) {
+	r := &Resolver{Log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	if err := r.WaitSubscriptions(context.Background()); err != nil {
+		t.Fatalf("WaitSubscriptions: unexpected error: %v", err)
+	}
+	if _, err := r.Subscription().PostEvents(context.Background(), "1", nil); !errors.Is(err, errShuttingDown) {
+		t.Fatalf("expected errShuttingDown, got %v", err)
+	}
+}
+
+func TestMapEventToModel_CommentChanges(t *testing.T) {
+	comment := &domain.Comment{ID: "c1", PostID: "p1"}
+	if _, ok := mapEventToModel(events.Event{Type: events.CommentEdited, Comment: comment}).(*model.CommentEdited); !ok {
+		t.Error("expected an edit to map to CommentEdited")
+	}
+	if _, ok := mapEventToModel(events.Event{Type: events.CommentVoted, Comment: comment}).(*model.CommentVoted); !ok {
+		t.Error("expected a vote to map to CommentVoted")
+	}
+	if _, ok := mapEventToModel(events.Event{Type: events.CommentLocked, Comment: comment}).(*model.CommentLocked); !ok {
+		t.Error("expected a lock to map to CommentLocked")
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	var opCtx context.Context
+	responses := StreamErrors{}.InterceptOperation(context.Background(), func(ctx context.Context) graphql.ResponseHandler {
+		opCtx = ctx
+		return func(ctx context.Context) *graphql.Response { return nil }
+	})
+	respond := func() *graphql.Response {
+		ctx := graphql.WithResponseContext(opCtx, graphql.DefaultErrorPresenter, nil)
+		return StreamErrors{}.InterceptResponse(ctx, responses)
+	}
+
+	if resp := respond(); resp != nil {
+		t.Fatalf("expected a stream without an error to end silently, got %+v", resp)
+	}
+
+	endStream(opCtx, events.ErrLagged)
+	resp := respond()
+	if resp == nil || len(resp.Errors) != 1 || !errors.Is(resp.Errors[0], events.ErrLagged) {
+		t.Fatalf("expected the lagged error, got %+v", resp)
+	}
+	if resp := respond(); resp != nil {
+		t.Fatalf("expected the error to be reported once, got %+v", resp)
+	}
+}
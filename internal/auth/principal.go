@@ -0,0 +1,40 @@
+// Package auth identifies the caller from a bearer JWT.
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+var ErrUnauthenticated = errors.New("authentication required")
+
+// Principal is the authenticated caller.
+type Principal struct {
+	Subject string
+	Roles   []string
+}
+
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// RequirePrincipal returns the caller or ErrUnauthenticated.
+func RequirePrincipal(ctx context.Context) (*Principal, error) {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	return p, nil
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles"`
+}
+
+// Verifier checks HMAC-signed tokens against a shared secret and RS256
+// tokens against keys from a JWKS file.
+type Verifier struct {
+	secret  []byte
+	rsaKeys map[string]*rsa.PublicKey
+	parser  *jwt.Parser
+}
+
+// NewVerifier accepts an empty secret or jwksFile to disable that kind of
+// token.
+func NewVerifier(secret, jwksFile string) (*Verifier, error) {
+	v := &Verifier{}
+	var methods []string
+
+	if secret != "" {
+		v.secret = []byte(secret)
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if jwksFile != "" {
+		keys, err := LoadJWKS(jwksFile)
+		if err != nil {
+			return nil, err
+		}
+		v.rsaKeys = keys
+		methods = append(methods, "RS256")
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("neither a JWT secret nor a JWKS file is configured")
+	}
+
+	v.parser = jwt.NewParser(
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+	)
+	return v, nil
+}
+
+func (v *Verifier) Verify(token string) (*Principal, error) {
+	c := &tokenClaims{}
+	if _, err := v.parser.ParseWithClaims(token, c, v.key); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if c.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+
+	return &Principal{Subject: c.Subject, Roles: c.Roles}, nil
+}
+
+func (v *Verifier) key(token *jwt.Token) (any, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return v.secret, nil
+	case *jwt.SigningMethodRSA:
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := v.rsaKeys[kid]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const secret = "test-secret"
+
+func TestVerifier_HMAC(t *testing.T) {
+	v, err := NewVerifier(secret, "")
+	if err != nil {
+		t.Fatalf("NewVerifier: unexpected error: %v", err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		p, err := v.Verify(sign(t, jwt.SigningMethodHS256, []byte(secret), "", claims("alice", time.Hour)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p.Subject != "alice" {
+			t.Fatalf("expected subject alice, got %q", p.Subject)
+		}
+	})
+
+	cases := map[string]string{
+		"wrong secret":    sign(t, jwt.SigningMethodHS256, []byte("other"), "", claims("alice", time.Hour)),
+		"expired":         sign(t, jwt.SigningMethodHS256, []byte(secret), "", claims("alice", -time.Minute)),
+		"no expiry":       sign(t, jwt.SigningMethodHS256, []byte(secret), "", jwt.RegisteredClaims{Subject: "alice"}),
+		"no subject":      sign(t, jwt.SigningMethodHS256, []byte(secret), "", claims("", time.Hour)),
+		"unsigned":        sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", claims("alice", time.Hour)),
+		"not a jwt":       "garbage",
+		"rsa not enabled": sign(t, jwt.SigningMethodRS256, newRSAKey(t), "k1", claims("alice", time.Hour)),
+	}
+	for name, token := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := v.Verify(token); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("expected ErrInvalidToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifier_RS256(t *testing.T) {
+	key := newRSAKey(t)
+	v, err := NewVerifier("", writeJWKS(t, "k1", &key.PublicKey))
+	if err != nil {
+		t.Fatalf("NewVerifier: unexpected error: %v", err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		p, err := v.Verify(sign(t, jwt.SigningMethodRS256, key, "k1", claims("bob", time.Hour)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p.Subject != "bob" {
+			t.Fatalf("expected subject bob, got %q", p.Subject)
+		}
+	})
+
+	cases := map[string]string{
+		"unknown key id":   sign(t, jwt.SigningMethodRS256, key, "k2", claims("bob", time.Hour)),
+		"other key":        sign(t, jwt.SigningMethodRS256, newRSAKey(t), "k1", claims("bob", time.Hour)),
+		"hmac not enabled": sign(t, jwt.SigningMethodHS256, []byte(secret), "", claims("bob", time.Hour)),
+	}
+	for name, token := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := v.Verify(token); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("expected ErrInvalidToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifier_Roles(t *testing.T) {
+	v, err := NewVerifier(secret, "")
+	if err != nil {
+		t.Fatalf("NewVerifier: unexpected error: %v", err)
+	}
+
+	token := sign(t, jwt.SigningMethodHS256, []byte(secret), "", tokenClaims{
+		RegisteredClaims: claims("carol", time.Hour),
+		Roles:            []string{"moderator"},
+	})
+	p, err := v.Verify(token)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !p.HasRole("moderator") || p.HasRole("admin") {
+		t.Fatalf("expected only the moderator role, got %v", p.Roles)
+	}
+}
+
+func TestNewVerifier_NothingConfigured(t *testing.T) {
+	if _, err := NewVerifier("", ""); err == nil {
+		t.Fatal("expected error without secret and JWKS file")
+	}
+}
+
+func claims(subject string, ttl time.Duration) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   subject,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return s
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func writeJWKS(t *testing.T, kid string, key *rsa.PublicKey) string {
+	t.Helper()
+	set := jwks{Keys: []jwk{{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("marshal jwks: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+	return path
+}
@@ -0,0 +1,123 @@
+// Package authz decides which principal may perform which action.
+package authz
+
+import (
+	"context"
+	"slices"
+
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/auth"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+)
+
+const (
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+type Action string
+
+const (
+	ActionToggleComments Action = "post:toggle_comments"
+	ActionEditPost       Action = "post:edit"
+	ActionEditComment    Action = "comment:edit"
+	ActionDeleteComment  Action = "comment:delete"
+	ActionVoteComment    Action = "comment:vote"
+	ActionLockComment    Action = "comment:lock"
+)
+
+// Resource is what an action is performed on.
+type Resource struct {
+	OwnerID string
+}
+
+// Rule reports whether p may act on r.
+type Rule func(p *auth.Principal, r Resource) bool
+
+// Authenticated allows every principal.
+func Authenticated() Rule {
+	return func(p *auth.Principal, r Resource) bool {
+		return true
+	}
+}
+
+func Owner() Rule {
+	return func(p *auth.Principal, r Resource) bool {
+		return r.OwnerID != "" && p.Subject == r.OwnerID
+	}
+}
+
+// AnyRole allows principals with one of roles. Admins are allowed
+// everything a moderator is.
+func AnyRole(roles ...string) Rule {
+	return func(p *auth.Principal, r Resource) bool {
+		return hasAnyRole(p, roles)
+	}
+}
+
+func AnyOf(rules ...Rule) Rule {
+	return func(p *auth.Principal, r Resource) bool {
+		for _, rule := range rules {
+			if rule(p, r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+type Policy struct {
+	rules map[Action]Rule
+}
+
+func NewPolicy(rules map[Action]Rule) *Policy {
+	return &Policy{rules: rules}
+}
+
+// DefaultPolicy holds the rules of every mutation of the service.
+func DefaultPolicy() *Policy {
+	return NewPolicy(map[Action]Rule{
+		ActionToggleComments: AnyOf(Owner(), AnyRole(RoleModerator, RoleAdmin)),
+		ActionEditPost:       Owner(),
+		ActionEditComment:    Owner(),
+		ActionDeleteComment:  Owner(),
+		ActionVoteComment:    Authenticated(),
+		ActionLockComment:    AnyRole(RoleModerator, RoleAdmin),
+	})
+}
+
+// Authorize returns auth.ErrUnauthenticated without a principal in ctx and
+// domain.ErrForbidden if the principal may not perform action on r. Actions
+// without a rule are denied.
+func (p *Policy) Authorize(ctx context.Context, action Action, r Resource) error {
+	principal, err := auth.RequirePrincipal(ctx)
+	if err != nil {
+		return err
+	}
+
+	rule, ok := p.rules[action]
+	if !ok || !rule(principal, r) {
+		return domain.ErrForbidden
+	}
+	return nil
+}
+
+// RequireRole is like Authorize for actions that depend only on the role
+// of the caller.
+func RequireRole(ctx context.Context, role string) error {
+	principal, err := auth.RequirePrincipal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !hasAnyRole(principal, []string{role}) {
+		return domain.ErrForbidden
+	}
+	return nil
+}
+
+func hasAnyRole(p *auth.Principal, roles []string) bool {
+	if p.HasRole(RoleAdmin) {
+		return true
+	}
+	return slices.ContainsFunc(roles, p.HasRole)
+}
@@ -0,0 +1,64 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/auth"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+)
+
+func TestPolicy_ToggleComments(t *testing.T) {
+	policy := DefaultPolicy()
+	post := Resource{OwnerID: "alice"}
+
+	cases := []struct {
+		name      string
+		principal *auth.Principal
+		want      error
+	}{
+		{"owner", &auth.Principal{Subject: "alice"}, nil},
+		{"moderator", &auth.Principal{Subject: "bob", Roles: []string{RoleModerator}}, nil},
+		{"admin", &auth.Principal{Subject: "bob", Roles: []string{RoleAdmin}}, nil},
+		{"other user", &auth.Principal{Subject: "bob"}, domain.ErrForbidden},
+		{"other role", &auth.Principal{Subject: "bob", Roles: []string{"editor"}}, domain.ErrForbidden},
+		{"anonymous", nil, auth.ErrUnauthenticated},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.principal != nil {
+				ctx = auth.WithPrincipal(ctx, tc.principal)
+			}
+			if err := policy.Authorize(ctx, ActionToggleComments, post); !errors.Is(err, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestPolicy_UnknownActionIsDenied(t *testing.T) {
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice"})
+	if err := DefaultPolicy().Authorize(ctx, "post:unknown", Resource{OwnerID: "alice"}); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	moderator := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "m", Roles: []string{RoleModerator}})
+	admin := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "a", Roles: []string{RoleAdmin}})
+
+	if err := RequireRole(moderator, RoleModerator); err != nil {
+		t.Fatalf("expected moderator to pass, got %v", err)
+	}
+	if err := RequireRole(admin, RoleModerator); err != nil {
+		t.Fatalf("expected admin to pass a moderator check, got %v", err)
+	}
+	if err := RequireRole(moderator, RoleAdmin); !errors.Is(err, domain.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	if err := RequireRole(context.Background(), RoleModerator); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Fatalf("expected ErrUnauthenticated, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+// Package cache stores serialized values under string keys with a TTL.
+// Callers own the encoding and treat every error as a miss: the cache is
+// never the source of truth.
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrMiss is returned by Get when the key is not cached or has expired.
+var ErrMiss = errors.New("cache: miss")
+
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	// GetMany returns the values of keys in order, nil for the ones that
+	// are not cached, in a single round trip.
+	GetMany(ctx context.Context, keys ...string) ([][]byte, error)
+	// Set stores value under key for ttl; a ttl of 0 keeps it until it is
+	// deleted or evicted.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	DeleteByPrefix(ctx context.Context, prefix string) error
+	// Incr increments the counter stored under key, starting from 0, and
+	// returns the new value. Get returns counters in decimal. Counters do
+	// not expire and are not evicted, so they can version other keys.
+	Incr(ctx context.Context, key string) (int64, error)
+}
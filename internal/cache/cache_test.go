@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pubsub"
+	"github.com/redis/go-redis/v9"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func newLRU(t *testing.T) Cache {
+	t.Helper()
+	c, err := NewLRU(100)
+	if err != nil {
+		t.Fatalf("NewLRU: unexpected error: %v", err)
+	}
+	return c
+}
+
+func TestLRU(t *testing.T) {
+	testCache(t, newLRU(t))
+
+	t.Run("evicts least recently used", func(t *testing.T) {
+		ctx := context.Background()
+		c, _ := NewLRU(2)
+		_ = c.Set(ctx, "a", []byte("1"), 0)
+		_ = c.Set(ctx, "b", []byte("2"), 0)
+		_, _ = c.Get(ctx, "a")
+		_ = c.Set(ctx, "c", []byte("3"), 0)
+		expectMiss(t, c, "b")
+		expectValue(t, c, "a", "1")
+	})
+}
+
+func TestTiered(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	broker := pubsub.NewMemoryBroker()
+	remote := newLRU(t)
+	first, err := NewTiered(ctx, newLRU(t), remote, broker, time.Minute, discard)
+	if err != nil {
+		t.Fatalf("NewTiered: unexpected error: %v", err)
+	}
+	testCache(t, first)
+
+	t.Run("invalidates other instances", func(t *testing.T) {
+		second, err := NewTiered(ctx, newLRU(t), remote, broker, time.Minute, discard)
+		if err != nil {
+			t.Fatalf("NewTiered: unexpected error: %v", err)
+		}
+
+		_ = first.Set(ctx, "post:1", []byte("v1"), time.Minute)
+		expectValue(t, second, "post:1", "v1")
+
+		_ = first.Set(ctx, "post:1", []byte("v2"), time.Minute)
+		eventually(t, func() bool {
+			v, err := second.Get(ctx, "post:1")
+			return err == nil && string(v) == "v2"
+		})
+
+		_ = second.Set(ctx, "posts:list:a", []byte("x"), time.Minute)
+		expectValue(t, first, "posts:list:a", "x")
+		_ = second.DeleteByPrefix(ctx, "posts:")
+		eventually(t, func() bool {
+			_, err := first.Get(ctx, "posts:list:a")
+			return errors.Is(err, ErrMiss)
+		})
+	})
+
+	t.Run("serves from the local tier", func(t *testing.T) {
+		_ = first.Set(ctx, "hot", []byte("v"), time.Minute)
+		_ = remote.Delete(ctx, "hot")
+		expectValue(t, first, "hot", "v")
+	})
+}
+
+// TestRedis runs against the server at REDIS_TEST_ADDR, e.g.
+// localhost:6379.
+func TestRedis(t *testing.T) {
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_TEST_ADDR is not set")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("failed to connect to redis: %v", err)
+	}
+
+	testCache(t, NewRedis(client))
+}
+
+func testCache(t *testing.T, c Cache) {
+	ctx := context.Background()
+	// Keys are unique per run so suites sharing a Redis server do not clash.
+	ns := "test:" + uuid.NewString() + ":"
+
+	t.Run("get set delete", func(t *testing.T) {
+		expectMiss(t, c, ns+"a")
+		if err := c.Set(ctx, ns+"a", []byte("1"), time.Minute); err != nil {
+			t.Fatalf("Set: unexpected error: %v", err)
+		}
+		expectValue(t, c, ns+"a", "1")
+
+		if err := c.Delete(ctx, ns+"a", ns+"missing"); err != nil {
+			t.Fatalf("Delete: unexpected error: %v", err)
+		}
+		expectMiss(t, c, ns+"a")
+	})
+
+	t.Run("get many", func(t *testing.T) {
+		_ = c.Set(ctx, ns+"m1", []byte("1"), time.Minute)
+		_ = c.Set(ctx, ns+"m3", []byte("3"), time.Minute)
+		values, err := c.GetMany(ctx, ns+"m1", ns+"m2", ns+"m3")
+		if err != nil {
+			t.Fatalf("GetMany: unexpected error: %v", err)
+		}
+		if len(values) != 3 || string(values[0]) != "1" || values[1] != nil || string(values[2]) != "3" {
+			t.Fatalf("GetMany: expected [1 nil 3], got %q", values)
+		}
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		_ = c.Set(ctx, ns+"short", []byte("1"), 20*time.Millisecond)
+		eventually(t, func() bool {
+			_, err := c.Get(ctx, ns+"short")
+			return errors.Is(err, ErrMiss)
+		})
+	})
+
+	t.Run("incr", func(t *testing.T) {
+		for want := int64(1); want <= 2; want++ {
+			n, err := c.Incr(ctx, ns+"version")
+			if err != nil {
+				t.Fatalf("Incr: unexpected error: %v", err)
+			}
+			if n != want {
+				t.Fatalf("Incr: expected %d, got %d", want, n)
+			}
+		}
+		expectValue(t, c, ns+"version", "2")
+	})
+
+	t.Run("delete by prefix", func(t *testing.T) {
+		_ = c.Set(ctx, ns+"posts:1", []byte("1"), time.Minute)
+		_ = c.Set(ctx, ns+"posts:2", []byte("2"), time.Minute)
+		_ = c.Set(ctx, ns+"post:1", []byte("3"), time.Minute)
+		_ = c.Set(ctx, ns+"posts*", []byte("4"), time.Minute)
+
+		if err := c.DeleteByPrefix(ctx, ns+"posts:"); err != nil {
+			t.Fatalf("DeleteByPrefix: unexpected error: %v", err)
+		}
+		expectMiss(t, c, ns+"posts:1")
+		expectMiss(t, c, ns+"posts:2")
+		expectValue(t, c, ns+"post:1", "3")
+		expectValue(t, c, ns+"posts*", "4")
+	})
+}
+
+func expectValue(t *testing.T, c Cache, key, want string) {
+	t.Helper()
+	got, err := c.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get(%s): unexpected error: %v", key, err)
+	}
+	if string(got) != want {
+		t.Fatalf("Get(%s): expected %q, got %q", key, want, got)
+	}
+}
+
+func expectMiss(t *testing.T, c Cache, key string) {
+	t.Helper()
+	if _, err := c.Get(context.Background(), key); !errors.Is(err, ErrMiss) {
+		t.Fatalf("Get(%s): expected ErrMiss, got %v", key, err)
+	}
+}
+
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+type entry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// LRU keeps at most size entries in process memory, evicting the least
+// recently used one first. Counters are kept apart and never evicted.
+type LRU struct {
+	entries *lru.Cache[string, entry]
+
+	mu       sync.Mutex
+	counters map[string]int64
+}
+
+func NewLRU(size int) (Cache, error) {
+	entries, err := lru.New[string, entry](size)
+	if err != nil {
+		return nil, err
+	}
+	return &LRU{entries: entries, counters: map[string]int64{}}, nil
+}
+
+func (c *LRU) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	n, ok := c.counters[key]
+	c.mu.Unlock()
+	if ok {
+		return strconv.AppendInt(nil, n, 10), nil
+	}
+
+	e, ok := c.entries.Get(key)
+	if !ok {
+		return nil, ErrMiss
+	}
+	if !e.expiresAt.IsZero() && time.Now().After(e.expiresAt) {
+		c.entries.Remove(key)
+		return nil, ErrMiss
+	}
+	return e.value, nil
+}
+
+func (c *LRU) GetMany(ctx context.Context, keys ...string) ([][]byte, error) {
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i], _ = c.Get(ctx, key)
+	}
+	return values, nil
+}
+
+func (c *LRU) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	e := entry{value: value}
+	if ttl > 0 {
+		e.expiresAt = time.Now().Add(ttl)
+	}
+	c.entries.Add(key, e)
+	return nil
+}
+
+func (c *LRU) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		c.entries.Remove(key)
+		delete(c.counters, key)
+	}
+	return nil
+}
+
+func (c *LRU) DeleteByPrefix(ctx context.Context, prefix string) error {
+	for _, key := range c.entries.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			c.entries.Remove(key)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.counters {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.counters, key)
+		}
+	}
+	return nil
+}
+
+func (c *LRU) Incr(ctx context.Context, key string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counters[key]++
+	return c.counters[key], nil
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// scanBatch is how many keys DeleteByPrefix asks Redis for at a time.
+const scanBatch = 100
+
+// Redis stores entries on a Redis server shared by every instance.
+type Redis struct {
+	client *redis.Client
+}
+
+func NewRedis(client *redis.Client) Cache {
+	return &Redis{client: client}
+}
+
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	return value, err
+}
+
+func (c *Redis) GetMany(ctx context.Context, keys ...string) ([][]byte, error) {
+	values := make([][]byte, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+	res, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range res {
+		if s, ok := v.(string); ok {
+			values[i] = []byte(s)
+		}
+	}
+	return values, nil
+}
+
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (c *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.client.Del(ctx, keys...).Err()
+}
+
+func (c *Redis) Incr(ctx context.Context, key string) (int64, error) {
+	return c.client.Incr(ctx, key).Result()
+}
+
+// DeleteByPrefix walks the keyspace with SCAN, so it does not block the
+// server but may miss keys written while it runs.
+func (c *Redis) DeleteByPrefix(ctx context.Context, prefix string) error {
+	iter := c.client.Scan(ctx, 0, escapePattern(prefix)+"*", scanBatch).Iterator()
+	keys := make([]string, 0, scanBatch)
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == scanBatch {
+			if err := c.client.Unlink(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return c.client.Unlink(ctx, keys...).Err()
+	}
+	return nil
+}
+
+var patternEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// escapePattern quotes the glob characters of a SCAN MATCH pattern.
+func escapePattern(s string) string {
+	return patternEscaper.Replace(s)
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pubsub"
+)
+
+// invalidationTopic is the broker topic instances announce changed keys
+// on.
+const invalidationTopic = "cache:invalidate"
+
+type invalidation struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys,omitempty"`
+	Prefix string   `json:"prefix,omitempty"`
+}
+
+// Tiered serves hot entries from a local cache in front of a shared remote
+// one. Writes go to both tiers and are announced on the broker so other
+// instances drop their local copies. Local entries live at most localTTL,
+// which bounds staleness should an announcement be lost.
+type Tiered struct {
+	local    Cache
+	remote   Cache
+	broker   pubsub.Broker
+	localTTL time.Duration
+	id       string
+	log      *slog.Logger
+}
+
+// NewTiered subscribes to invalidations from other instances until ctx is
+// done.
+func NewTiered(ctx context.Context, local, remote Cache, broker pubsub.Broker, localTTL time.Duration, log *slog.Logger) (Cache, error) {
+	t := &Tiered{
+		local:    local,
+		remote:   remote,
+		broker:   broker,
+		localTTL: localTTL,
+		id:       uuid.NewString(),
+		log:      log,
+	}
+
+	events, err := broker.Subscribe(ctx, invalidationTopic)
+	if err != nil {
+		return nil, err
+	}
+	go t.listen(ctx, events)
+
+	return t, nil
+}
+
+func (t *Tiered) Get(ctx context.Context, key string) ([]byte, error) {
+	if value, err := t.local.Get(ctx, key); err == nil {
+		return value, nil
+	}
+
+	value, err := t.remote.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	_ = t.local.Set(ctx, key, value, t.localTTL)
+	return value, nil
+}
+
+// GetMany asks the remote tier only for the keys missing locally.
+func (t *Tiered) GetMany(ctx context.Context, keys ...string) ([][]byte, error) {
+	values, _ := t.local.GetMany(ctx, keys...)
+	var missing []string
+	var at []int
+	for i, key := range keys {
+		if values[i] == nil {
+			missing = append(missing, key)
+			at = append(at, i)
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	remote, err := t.remote.GetMany(ctx, missing...)
+	if err != nil {
+		return nil, err
+	}
+	for j, value := range remote {
+		if value != nil {
+			values[at[j]] = value
+			_ = t.local.Set(ctx, missing[j], value, t.localTTL)
+		}
+	}
+	return values, nil
+}
+
+func (t *Tiered) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := t.remote.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	_ = t.local.Set(ctx, key, value, t.localTTLFor(ttl))
+	return t.announce(ctx, invalidation{Keys: []string{key}})
+}
+
+func (t *Tiered) Delete(ctx context.Context, keys ...string) error {
+	_ = t.local.Delete(ctx, keys...)
+	if err := t.remote.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	return t.announce(ctx, invalidation{Keys: keys})
+}
+
+func (t *Tiered) DeleteByPrefix(ctx context.Context, prefix string) error {
+	_ = t.local.DeleteByPrefix(ctx, prefix)
+	if err := t.remote.DeleteByPrefix(ctx, prefix); err != nil {
+		return err
+	}
+	return t.announce(ctx, invalidation{Prefix: prefix})
+}
+
+// Incr counts on the remote tier only, so every instance sees the same
+// sequence; other instances drop the value they may hold locally.
+func (t *Tiered) Incr(ctx context.Context, key string) (int64, error) {
+	n, err := t.remote.Incr(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	_ = t.local.Delete(ctx, key)
+	return n, t.announce(ctx, invalidation{Keys: []string{key}})
+}
+
+func (t *Tiered) localTTLFor(ttl time.Duration) time.Duration {
+	if ttl > 0 && ttl < t.localTTL {
+		return ttl
+	}
+	return t.localTTL
+}
+
+func (t *Tiered) announce(ctx context.Context, msg invalidation) error {
+	msg.Origin = t.id
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return t.broker.Publish(ctx, invalidationTopic, payload)
+}
+
+func (t *Tiered) listen(ctx context.Context, events <-chan []byte) {
+	for payload := range events {
+		var msg invalidation
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			t.log.Error("failed to decode cache invalidation", "error", err)
+			continue
+		}
+		if msg.Origin == t.id {
+			continue
+		}
+		if len(msg.Keys) > 0 {
+			_ = t.local.Delete(ctx, msg.Keys...)
+		}
+		if msg.Prefix != "" {
+			_ = t.local.DeleteByPrefix(ctx, msg.Prefix)
+		}
+	}
+}
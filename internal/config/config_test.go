@@ -0,0 +1,35 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	t.Setenv("CACHE_HARD_TTL", "10m")
+	t.Setenv("MAX_QUERY_DEPTH", "20")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.CacheHardTTL != 10*time.Minute || cfg.MaxQueryDepth != 20 {
+		t.Fatalf("expected the values from the environment, got %v and %d", cfg.CacheHardTTL, cfg.MaxQueryDepth)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	t.Setenv("CACHE_HARD_TTL", "5x")
+	t.Setenv("MAX_QUERY_DEPTH", "deep")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, key := range []string{"CACHE_HARD_TTL", "MAX_QUERY_DEPTH"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Fatalf("expected %s to be reported, got %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Package dataloader batches and caches lookups by key for the lifetime
+// of a single GraphQL response.
+package dataloader
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultWait     = 2 * time.Millisecond
+	DefaultMaxBatch = 100
+)
+
+// BatchFunc loads values for keys. Keys missing from the returned map
+// resolve to the zero value of V.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+type Loader[K comparable, V any] struct {
+	fetch    BatchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu    sync.Mutex
+	cache map[K]*result[V]
+	batch *batch[K, V]
+}
+
+type result[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+type batch[K comparable, V any] struct {
+	keys    []K
+	results []*result[V]
+}
+
+func New[K comparable, V any](fetch BatchFunc[K, V]) *Loader[K, V] {
+	return &Loader[K, V]{
+		fetch:    fetch,
+		wait:     DefaultWait,
+		maxBatch: DefaultMaxBatch,
+		cache:    make(map[K]*result[V]),
+	}
+}
+
+// Load returns the value for key, waiting briefly so that concurrent
+// loads can be served by a single call to the batch function.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	r, ok := l.cache[key]
+	if !ok {
+		r = &result[V]{done: make(chan struct{})}
+		l.cache[key] = r
+
+		if l.batch == nil {
+			l.batch = &batch[K, V]{}
+			go l.dispatchAfterWait(ctx, l.batch)
+		}
+		b := l.batch
+		b.keys = append(b.keys, key)
+		b.results = append(b.results, r)
+		if len(b.keys) >= l.maxBatch {
+			l.batch = nil
+			go l.run(ctx, b)
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+func (l *Loader[K, V]) dispatchAfterWait(ctx context.Context, b *batch[K, V]) {
+	time.Sleep(l.wait)
+
+	l.mu.Lock()
+	if l.batch != b {
+		// Already dispatched because it reached the size limit.
+		l.mu.Unlock()
+		return
+	}
+	l.batch = nil
+	l.mu.Unlock()
+
+	l.run(ctx, b)
+}
+
+func (l *Loader[K, V]) run(ctx context.Context, b *batch[K, V]) {
+	values, err := l.fetchRecovered(ctx, b.keys)
+
+	if err != nil {
+		// Failed keys are not cached so a later load can retry them.
+		l.mu.Lock()
+		for _, key := range b.keys {
+			delete(l.cache, key)
+		}
+		l.mu.Unlock()
+	}
+
+	for i, key := range b.keys {
+		r := b.results[i]
+		r.value, r.err = values[key], err
+		close(r.done)
+	}
+}
+
+// fetchRecovered calls the batch function, turning a panic into an error
+// for the waiting loads. The batch runs in its own goroutine, out of reach
+// of the recovery of the resolvers.
+func (l *Loader[K, V]) fetchRecovered(ctx context.Context, keys []K) (values map[K]V, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			values, err = nil, fmt.Errorf("panic in batch function: %v\n%s", p, debug.Stack())
+		}
+	}()
+	return l.fetch(ctx, keys)
+}
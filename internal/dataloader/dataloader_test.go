@@ -0,0 +1,106 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLoader_Batches(t *testing.T) {
+	var calls atomic.Int32
+	l := New(func(ctx context.Context, keys []int) (map[int]int, error) {
+		calls.Add(1)
+		res := make(map[int]int, len(keys))
+		for _, k := range keys {
+			res[k] = k * 10
+		}
+		return res, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(key int) {
+			defer wg.Done()
+			got, err := l.Load(context.Background(), key%5)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if got != (key%5)*10 {
+				t.Errorf("expected %d, got %d", (key%5)*10, got)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected a single batch, got %d", n)
+	}
+
+	if _, err := l.Load(context.Background(), 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected cached value, got %d batches", n)
+	}
+}
+
+func TestLoader_MaxBatch(t *testing.T) {
+	var calls atomic.Int32
+	l := New(func(ctx context.Context, keys []int) (map[int]int, error) {
+		calls.Add(1)
+		if len(keys) > DefaultMaxBatch {
+			t.Errorf("batch of %d keys exceeds limit", len(keys))
+		}
+		return nil, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < DefaultMaxBatch*2; i++ {
+		wg.Add(1)
+		go func(key int) {
+			defer wg.Done()
+			l.Load(context.Background(), key)
+		}(i)
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n < 2 {
+		t.Fatalf("expected at least 2 batches, got %d", n)
+	}
+}
+
+func TestLoader_ErrorIsNotCached(t *testing.T) {
+	fail := true
+	l := New(func(ctx context.Context, keys []string) (map[string]string, error) {
+		if fail {
+			return nil, errors.New("db error")
+		}
+		return map[string]string{"a": "A"}, nil
+	})
+
+	if _, err := l.Load(context.Background(), "a"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	fail = false
+	got, err := l.Load(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "A" {
+		t.Fatalf("expected A, got %q", got)
+	}
+}
+
+func TestLoader_PanicIsReturnedAsError(t *testing.T) {
+	l := New(func(ctx context.Context, keys []string) (map[string]string, error) {
+		panic("boom")
+	})
+
+	if _, err := l.Load(context.Background(), "a"); err == nil {
+		t.Fatal("expected error")
+	}
+}
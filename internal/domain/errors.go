@@ -0,0 +1,38 @@
+package domain
+
+import "errors"
+
+// Kinds of errors returned by repositories and services. Match them with
+// errors.Is; the concrete errors carry a message meant for clients.
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrValidation       = errors.New("validation failed")
+	ErrCommentsDisabled = errors.New("comments are disabled for this post")
+	ErrForbidden        = errors.New("forbidden")
+	ErrConflict         = errors.New("conflict")
+)
+
+// Error is an error of one of the kinds above.
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func (e *Error) Unwrap() error { return e.Kind }
+
+// NotFound reports that the named entity does not exist.
+func NotFound(entity string) error {
+	return &Error{Kind: ErrNotFound, Message: entity + " not found"}
+}
+
+func Validation(message string) error {
+	return &Error{Kind: ErrValidation, Message: message}
+}
+
+// Conflict reports that the request clashes with the current state, e.g.
+// editing a deleted comment.
+func Conflict(message string) error {
+	return &Error{Kind: ErrConflict, Message: message}
+}
@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+type PostFilter struct {
+	Author          *string
+	CommentsAllowed *bool
+	CreatedAfter    *time.Time
+	CreatedBefore   *time.Time
+}
+
+// CommentFilter narrows the top-level comments of a post.
+type CommentFilter struct {
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+type PostOrder string
+
+const (
+	PostOrderNewest PostOrder = "NEWEST"
+	PostOrderOldest PostOrder = "OLDEST"
+)
+
+type CommentOrder string
+
+const (
+	CommentOrderNew           CommentOrder = "NEW"
+	CommentOrderOld           CommentOrder = "OLD"
+	CommentOrderTop           CommentOrder = "TOP"
+	CommentOrderBest          CommentOrder = "BEST"
+	CommentOrderControversial CommentOrder = "CONTROVERSIAL"
+)
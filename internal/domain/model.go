@@ -1,19 +1,53 @@
 package domain
 
+import "time"
+
+// CommentTombstone replaces the text of a deleted comment that still has
+// replies.
+const CommentTombstone = "[deleted]"
+
 type Comment struct {
-	ID       string
-	PostID   string
-	ParentID *string
-	Author   string
-	Text     string
-	Children []*Comment
+	ID        string
+	PostID    string
+	ParentID  *string
+	Author    string
+	Text      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Edited    bool
+	DeletedAt *time.Time
+	Upvotes   int
+	Downvotes int
+	// Depth is 1 for top-level comments and one more than the parent's for
+	// replies.
+	Depth int
+	// Locked comments accept no new replies.
+	Locked     bool
+	ReplyCount int
+	// Children is set only for comments loaded as part of a depth-limited
+	// tree. HasMoreReplies marks the ones whose replies were cut off.
+	Children       []*Comment
+	HasMoreReplies bool
 }
 
 type Post struct {
-	ID       string
-	Title    string
-	Content  string
-	Author   string
-	Flag     bool
-	Comments []*Comment
+	ID      string
+	Title   string
+	Content string
+	Author  string
+	Flag    bool
+	// Revision numbers the current version of the post, starting at 1.
+	Revision  int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// PostRevision is a previous version of a post, kept when the post is
+// edited. CreatedAt is when that version was written.
+type PostRevision struct {
+	PostID    string
+	Revision  int
+	Title     string
+	Content   string
+	CreatedAt time.Time
 }
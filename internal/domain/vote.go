@@ -0,0 +1,37 @@
+package domain
+
+import "math"
+
+type VoteValue int
+
+const (
+	VoteDown VoteValue = -1
+	VoteNone VoteValue = 0
+	VoteUp   VoteValue = 1
+)
+
+// wilsonZ is the z-score of a 95% confidence level.
+const wilsonZ = 1.96
+
+// WilsonScore is the lower bound of the confidence interval for the share
+// of upvotes, so that a few votes rank below many votes with the same
+// ratio.
+func WilsonScore(upvotes, downvotes int) float64 {
+	n := float64(upvotes + downvotes)
+	if n == 0 {
+		return 0
+	}
+	p := float64(upvotes) / n
+	z2 := wilsonZ * wilsonZ
+	return (p + z2/(2*n) - wilsonZ*math.Sqrt((p*(1-p)+z2/(4*n))/n)) / (1 + z2/n)
+}
+
+// Controversy ranks comments with many votes split evenly between up and
+// down highest.
+func Controversy(upvotes, downvotes int) float64 {
+	if upvotes <= 0 || downvotes <= 0 {
+		return 0
+	}
+	balance := float64(min(upvotes, downvotes)) / float64(max(upvotes, downvotes))
+	return math.Pow(float64(upvotes+downvotes), balance)
+}
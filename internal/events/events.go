@@ -0,0 +1,166 @@
+// Package events carries changes to posts and their comments from the
+// services to the subscribers of the post, on any instance of the server.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pubsub"
+)
+
+type Type string
+
+const (
+	CommentAdded    Type = "comment_added"
+	CommentEdited   Type = "comment_edited"
+	CommentVoted    Type = "comment_voted"
+	CommentLocked   Type = "comment_locked"
+	CommentDeleted  Type = "comment_deleted"
+	CommentsToggled Type = "comments_toggled"
+	PostUpdated     Type = "post_updated"
+	// Lagged is never published. Subscribe delivers it, as the last event,
+	// when events after Seq were lost and cannot be replayed.
+	Lagged Type = "lagged"
+)
+
+// Event is a change to a post. Only the fields that apply to Type are set:
+// Comment for added, edited, voted and locked comments, CommentID and, for comments left
+// as a tombstone, Comment for deleted ones, CommentsAllowed for toggled
+// comments and Post for updated posts.
+// Seq numbers the events of a post; it is 0 if numbering failed.
+type Event struct {
+	Type            Type            `json:"type"`
+	PostID          string          `json:"postId"`
+	Seq             int64           `json:"seq"`
+	CommentID       string          `json:"commentId,omitempty"`
+	Comment         *domain.Comment `json:"comment,omitempty"`
+	CommentsAllowed bool            `json:"commentsAllowed,omitempty"`
+	Post            *domain.Post    `json:"post,omitempty"`
+}
+
+// Bus publishes events on a broker topic per post, recording them in a
+// Log first. Subscribers use the log to resume from an earlier event and
+// to recover events the broker dropped. A nil Bus drops every event.
+type Bus struct {
+	broker pubsub.Broker
+	events Log
+	log    *slog.Logger
+}
+
+func NewBus(broker pubsub.Broker, events Log, log *slog.Logger) *Bus {
+	return &Bus{broker: broker, events: events, log: log}
+}
+
+func topic(postID string) string { return "post_events:" + postID }
+
+// Publish delivers e to the subscribers of its post. The change has been
+// made by then, so failures are logged rather than returned.
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	if b == nil {
+		return
+	}
+	numbered, err := b.events.Append(ctx, e)
+	if err != nil {
+		b.log.Error("failed to append event to log", "type", e.Type, "postID", e.PostID, "error", err)
+	} else {
+		e = numbered
+	}
+
+	payload, err := json.Marshal(e)
+	if err == nil {
+		err = b.broker.Publish(ctx, topic(e.PostID), payload)
+	}
+	if err != nil {
+		b.log.Error("failed to publish event", "type", e.Type, "postID", e.PostID, "error", err)
+	}
+}
+
+// Subscribe delivers the events of a post in sequence order, without
+// gaps. With a nil since it starts with the events published after it
+// returns; otherwise it first replays the events numbered above *since,
+// failing with ErrLagged if they are no longer in the log. Events missed
+// later are replayed from the log as well, and if that is not possible a
+// Lagged event ends the subscription, as it does for a subscriber the
+// broker drops for falling behind. The channel is closed once ctx is done.
+func (b *Bus) Subscribe(ctx context.Context, postID string, since *int64) (<-chan Event, error) {
+	// Subscribe before reading the log so that nothing published in
+	// between is missed. Events seen in both are delivered once.
+	payloads, err := b.broker.Subscribe(ctx, topic(postID))
+	if err != nil {
+		return nil, err
+	}
+
+	// last is the sequence number of the last event delivered, -1 until
+	// the position in the sequence is known.
+	last := int64(-1)
+	var replay []Event
+	if since != nil {
+		if replay, err = b.events.Since(ctx, postID, *since); err != nil {
+			return nil, err
+		}
+		last = *since
+	}
+
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+
+		send := func(e Event) bool {
+			select {
+			case ch <- e:
+				if e.Seq != 0 {
+					last = e.Seq
+				}
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for _, e := range replay {
+			if !send(e) {
+				return
+			}
+		}
+
+		for payload := range payloads {
+			var e Event
+			if err := json.Unmarshal(payload, &e); err != nil {
+				b.log.Error("failed to decode event", "postID", postID, "error", err)
+				continue
+			}
+
+			if e.Seq != 0 && last >= 0 && e.Seq > last+1 {
+				missed, err := b.events.Since(ctx, postID, last)
+				if err != nil {
+					if !errors.Is(err, ErrLagged) {
+						b.log.Error("failed to replay events", "postID", postID, "error", err)
+					}
+					send(Event{Type: Lagged, PostID: postID, Seq: last})
+					return
+				}
+				for _, m := range missed {
+					if !send(m) {
+						return
+					}
+				}
+			}
+			if e.Seq != 0 && e.Seq <= last {
+				continue
+			}
+			if !send(e) {
+				return
+			}
+		}
+		// The broker closes the channel early once it drops an event for
+		// this subscriber.
+		if ctx.Err() == nil {
+			send(Event{Type: Lagged, PostID: postID, Seq: max(last, 0)})
+		}
+	}()
+	return ch, nil
+}
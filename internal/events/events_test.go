@@ -0,0 +1,222 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pubsub"
+	"github.com/redis/go-redis/v9"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestMemoryLog(t *testing.T) {
+	testLog(t, NewMemoryLog(3))
+}
+
+// TestRedisLog runs against the server at REDIS_TEST_ADDR, e.g.
+// localhost:6379.
+func TestRedisLog(t *testing.T) {
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_TEST_ADDR is not set")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("failed to connect to redis: %v", err)
+	}
+
+	// Streams are trimmed approximately, so a small size may keep more.
+	testLog(t, NewRedisLog(client, 3))
+}
+
+func testLog(t *testing.T, l Log) {
+	ctx := context.Background()
+	// Posts are unique per run so suites sharing a Redis server do not clash.
+	post, other := uuid.NewString(), uuid.NewString()
+
+	for i := int64(1); i <= 2; i++ {
+		e, err := l.Append(ctx, Event{Type: CommentAdded, PostID: post, Comment: &domain.Comment{ID: "c"}})
+		if err != nil {
+			t.Fatalf("Append: unexpected error: %v", err)
+		}
+		if e.Seq != i {
+			t.Fatalf("Append: expected seq %d, got %d", i, e.Seq)
+		}
+	}
+	if e, _ := l.Append(ctx, Event{Type: PostUpdated, PostID: other}); e.Seq != 1 {
+		t.Fatalf("Append: expected every post to be numbered from 1, got %d", e.Seq)
+	}
+
+	got, err := l.Since(ctx, post, 0)
+	if err != nil {
+		t.Fatalf("Since: unexpected error: %v", err)
+	}
+	expectSeqs(t, got, 1, 2)
+	if got[0].Comment == nil || got[0].Comment.ID != "c" {
+		t.Fatalf("Since: expected the stored event, got %+v", got[0])
+	}
+
+	if got, err = l.Since(ctx, post, 2); err != nil || len(got) != 0 {
+		t.Fatalf("Since: expected nothing after the last event, got %v, %v", got, err)
+	}
+	if got, err = l.Since(ctx, uuid.NewString(), 0); err != nil || len(got) != 0 {
+		t.Fatalf("Since: expected nothing for an unknown post, got %v, %v", got, err)
+	}
+	if _, err := l.Since(ctx, post, 3); !errors.Is(err, ErrLagged) {
+		t.Fatalf("Since: expected ErrLagged past the last event, got %v", err)
+	}
+	if _, err := l.Since(ctx, uuid.NewString(), 5); !errors.Is(err, ErrLagged) {
+		t.Fatalf("Since: expected ErrLagged for a post the log does not know, got %v", err)
+	}
+
+	for range 300 {
+		_, _ = l.Append(ctx, Event{Type: CommentAdded, PostID: post})
+	}
+	if _, err := l.Since(ctx, post, 1); !errors.Is(err, ErrLagged) {
+		t.Fatalf("Since: expected ErrLagged for trimmed events, got %v", err)
+	}
+	if got, err = l.Since(ctx, post, 300); err != nil {
+		t.Fatalf("Since: unexpected error: %v", err)
+	}
+	expectSeqs(t, got, 301, 302)
+}
+
+func TestBus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	log := NewMemoryLog(2)
+	bus := NewBus(pubsub.NewMemoryBroker(), log, discard)
+
+	bus.Publish(ctx, Event{Type: CommentAdded, PostID: "p1", Comment: &domain.Comment{ID: "c1"}})
+	since := int64(0)
+	events, err := bus.Subscribe(ctx, "p1", &since)
+	if err != nil {
+		t.Fatalf("Subscribe: unexpected error: %v", err)
+	}
+	bus.Publish(ctx, Event{Type: CommentAdded, PostID: "p2", Comment: &domain.Comment{ID: "other"}})
+	bus.Publish(ctx, Event{Type: CommentsToggled, PostID: "p1"})
+
+	if e := expectEvent(t, events); e.Type != CommentAdded || e.Seq != 1 || e.Comment.ID != "c1" {
+		t.Fatalf("expected c1 to be replayed, got %+v", e)
+	}
+	if e := expectEvent(t, events); e.Type != CommentsToggled || e.Seq != 2 || e.CommentsAllowed {
+		t.Fatalf("expected comments to be disabled, got %+v", e)
+	}
+
+	t.Run("recovers dropped events", func(t *testing.T) {
+		// Events appended without being published stand in for events the
+		// broker dropped.
+		_, _ = log.Append(ctx, Event{Type: CommentEdited, PostID: "p1"})
+		bus.Publish(ctx, Event{Type: PostUpdated, PostID: "p1"})
+
+		if e := expectEvent(t, events); e.Type != CommentEdited || e.Seq != 3 {
+			t.Fatalf("expected the dropped event, got %+v", e)
+		}
+		if e := expectEvent(t, events); e.Type != PostUpdated || e.Seq != 4 {
+			t.Fatalf("expected the published event, got %+v", e)
+		}
+	})
+
+	t.Run("signals lag", func(t *testing.T) {
+		for range 3 {
+			_, _ = log.Append(ctx, Event{Type: CommentEdited, PostID: "p1"})
+		}
+		bus.Publish(ctx, Event{Type: PostUpdated, PostID: "p1"})
+
+		if e := expectEvent(t, events); e.Type != Lagged || e.Seq != 4 {
+			t.Fatalf("expected a lagged event after seq 4, got %+v", e)
+		}
+		if _, ok := <-events; ok {
+			t.Fatal("expected the subscription to end")
+		}
+	})
+
+	t.Run("resume too far back", func(t *testing.T) {
+		since := int64(1)
+		if _, err := bus.Subscribe(ctx, "p1", &since); !errors.Is(err, ErrLagged) {
+			t.Fatalf("expected ErrLagged, got %v", err)
+		}
+	})
+
+	t.Run("resume past the head", func(t *testing.T) {
+		// A restarted server numbers p1 from 1 again, and a fresh log does
+		// not know p3 at all.
+		restarted := NewBus(pubsub.NewMemoryBroker(), NewMemoryLog(2), discard)
+		restarted.Publish(ctx, Event{Type: PostUpdated, PostID: "p1"})
+		for _, post := range []string{"p1", "p3"} {
+			since := int64(7)
+			if _, err := restarted.Subscribe(ctx, post, &since); !errors.Is(err, ErrLagged) {
+				t.Fatalf("%s: expected ErrLagged, got %v", post, err)
+			}
+		}
+	})
+
+	t.Run("signals lag when the last event is dropped", func(t *testing.T) {
+		bus := NewBus(pubsub.NewMemoryBroker(), NewMemoryLog(100), discard)
+		events, err := bus.Subscribe(ctx, "p4", nil)
+		if err != nil {
+			t.Fatalf("Subscribe: unexpected error: %v", err)
+		}
+		// Nothing is read until the broker has dropped the subscriber, and
+		// nothing is published after the dropped event.
+		const published = 20
+		for range published {
+			bus.Publish(ctx, Event{Type: CommentEdited, PostID: "p4"})
+		}
+
+		var last int64
+		for {
+			e := expectEvent(t, events)
+			if e.Type == Lagged {
+				if e.Seq != last || last >= published {
+					t.Fatalf("expected a lagged event after seq %d, got %+v", last, e)
+				}
+				break
+			}
+			if e.Seq != last+1 {
+				t.Fatalf("expected seq %d, got %+v", last+1, e)
+			}
+			last = e.Seq
+		}
+		if _, ok := <-events; ok {
+			t.Fatal("expected the subscription to end")
+		}
+	})
+
+	var nilBus *Bus
+	nilBus.Publish(context.Background(), Event{Type: PostUpdated, PostID: "p1"})
+}
+
+func expectEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("expected an event")
+		return Event{}
+	}
+}
+
+func expectSeqs(t *testing.T, events []Event, want ...int64) {
+	t.Helper()
+	if len(events) != len(want) {
+		t.Fatalf("expected seqs %v, got %d events", want, len(events))
+	}
+	for i, e := range events {
+		if e.Seq != want[i] {
+			t.Fatalf("expected seqs %v, got %d at %d", want, e.Seq, i)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package events
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// ErrLagged reports that a subscriber fell behind the replay log: some of
+// the events it has not seen were already dropped.
+var ErrLagged = errors.New("subscriber lagged behind the event log")
+
+// Log numbers the events of every post and keeps the latest ones so that
+// subscribers can catch up on what they missed.
+type Log interface {
+	// Append stores e under the next sequence number of its post, starting
+	// from 1, and returns it with Seq set.
+	Append(ctx context.Context, e Event) (Event, error)
+	// Since returns the stored events of a post numbered above seq, oldest
+	// first. It fails with ErrLagged if some of them were already dropped,
+	// or if seq is past the last event of the post: the log lost the post,
+	// for instance with a restart, and the position of the caller is
+	// meaningless.
+	Since(ctx context.Context, postID string, seq int64) ([]Event, error)
+}
+
+// MemoryLog keeps the last size events of every post in process memory.
+// Sequence numbers restart with the process, so it is only meant for a
+// single instance.
+type MemoryLog struct {
+	size  int
+	mu    sync.Mutex
+	posts map[string]*postLog
+}
+
+type postLog struct {
+	last   int64
+	events []Event
+}
+
+func NewMemoryLog(size int) Log {
+	return &MemoryLog{size: max(size, 1), posts: make(map[string]*postLog)}
+}
+
+func (l *MemoryLog) Append(ctx context.Context, e Event) (Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	p, ok := l.posts[e.PostID]
+	if !ok {
+		p = &postLog{}
+		l.posts[e.PostID] = p
+	}
+	p.last++
+	e.Seq = p.last
+	p.events = append(p.events, e)
+	if len(p.events) > l.size {
+		p.events = slices.Delete(p.events, 0, len(p.events)-l.size)
+	}
+	return e, nil
+}
+
+func (l *MemoryLog) Since(ctx context.Context, postID string, seq int64) ([]Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var last int64
+	p, ok := l.posts[postID]
+	if ok {
+		last = p.last
+	}
+	switch {
+	case seq > last:
+		return nil, ErrLagged
+	case seq == last:
+		return nil, nil
+	case p.events[0].Seq > seq+1:
+		return nil, ErrLagged
+	}
+	i, _ := slices.BinarySearchFunc(p.events, seq+1, func(e Event, seq int64) int { return cmp.Compare(e.Seq, seq) })
+	return slices.Clone(p.events[i:]), nil
+}
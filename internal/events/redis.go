@@ -0,0 +1,104 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// appendScript numbers an event and adds it to the stream of its post in
+// one step, so stream entries are always in sequence order. Entry ids are
+// "<seq>-0". Should the counter be lost while the stream is kept, numbering
+// resumes after the last entry.
+var appendScript = redis.NewScript(`
+local seq = redis.call('INCR', KEYS[1])
+if seq == 1 then
+  local top = redis.call('XREVRANGE', KEYS[2], '+', '-', 'COUNT', 1)
+  if #top > 0 then
+    seq = tonumber(string.match(top[1][1], '^%d+')) + 1
+    redis.call('SET', KEYS[1], seq)
+  end
+end
+redis.call('XADD', KEYS[2], 'MAXLEN', '~', ARGV[2], seq .. '-0', 'event', ARGV[1])
+return seq
+`)
+
+// RedisLog keeps the events of every post in a Redis stream trimmed to
+// about size entries, shared by every instance.
+type RedisLog struct {
+	client *redis.Client
+	size   int
+}
+
+func NewRedisLog(client *redis.Client, size int) Log {
+	return &RedisLog{client: client, size: max(size, 1)}
+}
+
+// The keys of a post share a hash tag so the script may use both on a
+// cluster.
+func seqKey(postID string) string    { return "post_events:{" + postID + "}:seq" }
+func streamKey(postID string) string { return "post_events:{" + postID + "}:log" }
+
+func (l *RedisLog) Append(ctx context.Context, e Event) (Event, error) {
+	e.Seq = 0
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return Event{}, err
+	}
+	seq, err := appendScript.Run(ctx, l.client, []string{seqKey(e.PostID), streamKey(e.PostID)}, payload, l.size).Int64()
+	if err != nil {
+		return Event{}, err
+	}
+	e.Seq = seq
+	return e, nil
+}
+
+func (l *RedisLog) Since(ctx context.Context, postID string, seq int64) ([]Event, error) {
+	// The counter is read first: events appended in between only move it
+	// forward, and their entries are then in the range.
+	var lastCmd *redis.StringCmd
+	var rangeCmd *redis.XMessageSliceCmd
+	if _, err := l.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		lastCmd = pipe.Get(ctx, seqKey(postID))
+		rangeCmd = pipe.XRange(ctx, streamKey(postID), fmt.Sprintf("%d-1", seq), "+")
+		return nil
+	}); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	last, err := lastCmd.Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	if seq > last {
+		return nil, ErrLagged
+	}
+	msgs, err := rangeCmd.Result()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, 0, len(msgs))
+	for _, msg := range msgs {
+		var e Event
+		payload, _ := msg.Values["event"].(string)
+		if err := json.Unmarshal([]byte(payload), &e); err != nil {
+			return nil, fmt.Errorf("decode event %s: %w", msg.ID, err)
+		}
+		ms, _, _ := strings.Cut(msg.ID, "-")
+		if e.Seq, err = strconv.ParseInt(ms, 10, 64); err != nil {
+			return nil, fmt.Errorf("parse event id %s: %w", msg.ID, err)
+		}
+		events = append(events, e)
+	}
+	// The stream always keeps the latest entries, so only the start can be
+	// missing.
+	if seq < last && (len(events) == 0 || events[0].Seq > seq+1) {
+		return nil, ErrLagged
+	}
+	return events, nil
+}
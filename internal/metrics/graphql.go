@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// GraphQL is a gqlgen extension recording the latency of queries and
+// mutations and the errors of every response, labelled with the operation
+// name. Subscriptions only report errors since they have no meaningful
+// duration.
+type GraphQL struct {
+	// Operations lists the operation names used as labels. Clients choose
+	// the names freely, so any other one is labelled "other" to bound the
+	// number of series.
+	Operations []string
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = GraphQL{}
+
+func (GraphQL) ExtensionName() string { return "Metrics" }
+
+func (GraphQL) Validate(graphql.ExecutableSchema) error { return nil }
+
+func (m GraphQL) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	resp := next(ctx)
+
+	name, typ := "", "invalid"
+	if graphql.HasOperationContext(ctx) {
+		oc := graphql.GetOperationContext(ctx)
+		name = oc.OperationName
+		if oc.Operation != nil {
+			typ = string(oc.Operation.Operation)
+			if name == "" {
+				name = oc.Operation.Name
+			}
+		}
+		if typ != string(ast.Subscription) && !oc.Stats.OperationStart.IsZero() {
+			OperationDuration.WithLabelValues(m.operationLabel(name), typ).
+				Observe(time.Since(oc.Stats.OperationStart).Seconds())
+		}
+	}
+
+	if resp != nil {
+		for _, err := range resp.Errors {
+			code, _ := err.Extensions["code"].(string)
+			if code == "" {
+				code = "GRAPHQL_ERROR"
+			}
+			OperationErrors.WithLabelValues(m.operationLabel(name), typ, code).Inc()
+		}
+	}
+	return resp
+}
+
+func (m GraphQL) operationLabel(name string) string {
+	switch {
+	case name == "":
+		return "anonymous"
+	case slices.Contains(m.Operations, name):
+		return name
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,96 @@
+// Package metrics declares the Prometheus metrics of the server. They are
+// registered with the default registry and served by Handler.
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "comments"
+
+var (
+	OperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "graphql_operation_duration_seconds",
+		Help:      "Time spent executing GraphQL queries and mutations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "type"})
+
+	OperationErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "graphql_operation_errors_total",
+		Help:      "Errors returned by GraphQL operations, by extensions.code.",
+	}, []string{"operation", "type", "code"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups, by cache and result (hit, stale or miss).",
+	}, []string{"cache", "result"})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Time spent in PostgreSQL repository methods.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"query"})
+
+	activeSubscribers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_subscribers",
+		Help:      "Open commentAdded and postEvents subscriptions, by post.",
+	}, []string{"post_id"})
+)
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// CacheHit, CacheStale and CacheMiss count a lookup in the named cache.
+// Stale lookups are served while the value is refreshed.
+func CacheHit(cache string)   { CacheRequests.WithLabelValues(cache, "hit").Inc() }
+func CacheStale(cache string) { CacheRequests.WithLabelValues(cache, "stale").Inc() }
+func CacheMiss(cache string)  { CacheRequests.WithLabelValues(cache, "miss").Inc() }
+
+// ObserveQuery starts timing a database query and returns the function that
+// records it, meant to be deferred.
+func ObserveQuery(query string) func() {
+	start := time.Now()
+	return func() {
+		DBQueryDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+	}
+}
+
+var (
+	subscribersMu sync.Mutex
+	subscribers   = map[string]int{}
+)
+
+// SubscriberAdded and SubscriberRemoved track the subscribers of a post.
+// The series of a post is dropped once its last subscriber leaves so the
+// gauge only lists posts that are being watched.
+func SubscriberAdded(postID string) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	subscribers[postID]++
+	activeSubscribers.WithLabelValues(postID).Set(float64(subscribers[postID]))
+}
+
+func SubscriberRemoved(postID string) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	subscribers[postID]--
+	if subscribers[postID] <= 0 {
+		delete(subscribers, postID)
+		activeSubscribers.DeleteLabelValues(postID)
+		return
+	}
+	activeSubscribers.WithLabelValues(postID).Set(float64(subscribers[postID]))
+}
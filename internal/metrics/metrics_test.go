@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func TestSubscribers(t *testing.T) {
+	SubscriberAdded("p1")
+	SubscriberAdded("p1")
+	SubscriberAdded("p2")
+	if got := testutil.ToFloat64(activeSubscribers.WithLabelValues("p1")); got != 2 {
+		t.Fatalf("expected 2 subscribers of p1, got %v", got)
+	}
+
+	SubscriberRemoved("p1")
+	SubscriberRemoved("p1")
+	SubscriberRemoved("p2")
+	if n := testutil.CollectAndCount(activeSubscribers); n != 0 {
+		t.Fatalf("expected no series once everyone left, got %d", n)
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	oc := &graphql.OperationContext{
+		OperationName: "PostPage",
+		Operation:     &ast.OperationDefinition{Operation: ast.Query},
+		Stats:         graphql.Stats{OperationStart: time.Now()},
+	}
+	ctx := graphql.WithOperationContext(context.Background(), oc)
+
+	respond := func(m GraphQL) {
+		m.InterceptResponse(ctx, func(ctx context.Context) *graphql.Response {
+			return &graphql.Response{Errors: gqlerror.List{
+				{Message: "post not found", Extensions: map[string]any{"code": "NOT_FOUND"}},
+			}}
+		})
+	}
+	respond(GraphQL{Operations: []string{"PostPage"}})
+
+	if n := testutil.CollectAndCount(OperationDuration, "comments_graphql_operation_duration_seconds"); n != 1 {
+		t.Fatalf("expected one latency series, got %d", n)
+	}
+	if got := testutil.ToFloat64(OperationErrors.WithLabelValues("PostPage", "query", "NOT_FOUND")); got != 1 {
+		t.Fatalf("expected one NOT_FOUND error, got %v", got)
+	}
+
+	respond(GraphQL{})
+	if got := testutil.ToFloat64(OperationErrors.WithLabelValues("other", "query", "NOT_FOUND")); got != 1 {
+		t.Fatalf("expected an unlisted operation to be labelled other, got %v", got)
+	}
+}
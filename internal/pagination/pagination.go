@@ -0,0 +1,136 @@
+// Package pagination implements Relay-style cursor pagination on top of
+// keyset queries ordered by (created_at, id).
+package pagination
+
+import (
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var ErrInvalidCursor = domain.Validation("invalid cursor")
+
+// Cursor points at a row by its position in the (created_at, id) order.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+func (c Cursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// Args are the connection arguments as received from the client.
+type Args struct {
+	First  *int
+	After  *string
+	Last   *int
+	Before *string
+}
+
+// Window is the slice of rows a repository has to return for a page.
+// Rows are always returned in the natural order of the listing; when
+// Backward is set they are the Limit rows closest to Before, otherwise
+// the Limit rows following After. Limit includes one extra row that is
+// used to find out whether there are more pages.
+type Window struct {
+	After    *Cursor
+	Before   *Cursor
+	Limit    int
+	Backward bool
+}
+
+func (a Args) Window() (Window, error) {
+	if a.First != nil && a.Last != nil {
+		return Window{}, domain.Validation("first and last cannot be used together")
+	}
+
+	size := DefaultPageSize
+	w := Window{}
+	switch {
+	case a.First != nil:
+		size = *a.First
+	case a.Last != nil:
+		size = *a.Last
+		w.Backward = true
+	case a.Before != nil && a.After == nil:
+		w.Backward = true
+	}
+	if size < 0 {
+		return Window{}, domain.Validation("page size must not be negative")
+	}
+	if size > MaxPageSize {
+		size = MaxPageSize
+	}
+	w.Limit = size + 1
+
+	if a.After != nil {
+		c, err := DecodeCursor(*a.After)
+		if err != nil {
+			return Window{}, err
+		}
+		w.After = &c
+	}
+	if a.Before != nil {
+		c, err := DecodeCursor(*a.Before)
+		if err != nil {
+			return Window{}, err
+		}
+		w.Before = &c
+	}
+
+	return w, nil
+}
+
+// Page is one page of a connection.
+type Page[T any] struct {
+	Items           []T
+	HasNextPage     bool
+	HasPreviousPage bool
+}
+
+// NewPage trims the extra row fetched for w and fills in page info.
+func NewPage[T any](items []T, w Window) Page[T] {
+	size := w.Limit - 1
+	page := Page[T]{
+		HasNextPage:     w.Before != nil,
+		HasPreviousPage: w.After != nil,
+	}
+
+	if len(items) > size {
+		if w.Backward {
+			items = items[len(items)-size:]
+			page.HasPreviousPage = true
+		} else {
+			items = items[:size]
+			page.HasNextPage = true
+		}
+	}
+	page.Items = items
+
+	return page
+}
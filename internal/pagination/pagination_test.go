@@ -0,0 +1,72 @@
+package pagination
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	want := Cursor{CreatedAt: time.Date(2026, 1, 30, 21, 9, 56, 123456789, time.UTC), ID: "post|1"}
+
+	got, err := DecodeCursor(want.Encode())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	for _, s := range []string{"", "not base64!", Cursor{}.Encode()[:4]} {
+		if _, err := DecodeCursor(s); err == nil {
+			t.Errorf("expected error for cursor %q", s)
+		}
+	}
+}
+
+func TestArgsWindow(t *testing.T) {
+	n := func(v int) *int { return &v }
+	cursor := Cursor{CreatedAt: time.Now(), ID: "1"}.Encode()
+
+	t.Run("defaults", func(t *testing.T) {
+		w, err := Args{}.Window()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if w.Limit != DefaultPageSize+1 || w.Backward {
+			t.Fatalf("unexpected window %+v", w)
+		}
+	})
+
+	t.Run("last is backward", func(t *testing.T) {
+		w, err := Args{Last: n(5), Before: &cursor}.Window()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if w.Limit != 6 || !w.Backward || w.Before == nil {
+			t.Fatalf("unexpected window %+v", w)
+		}
+	})
+
+	t.Run("page size is capped", func(t *testing.T) {
+		w, err := Args{First: n(MaxPageSize * 10)}.Window()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if w.Limit != MaxPageSize+1 {
+			t.Fatalf("expected limit %d, got %d", MaxPageSize+1, w.Limit)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		bad := "bad"
+		for name, args := range map[string]Args{
+			"first and last": {First: n(1), Last: n(1)},
+			"negative":       {First: n(-1)},
+			"bad cursor":     {After: &bad},
+		} {
+			if _, err := args.Window(); err == nil {
+				t.Errorf("%s: expected error", name)
+			}
+		}
+	})
+}
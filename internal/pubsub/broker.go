@@ -0,0 +1,13 @@
+// Package pubsub fans events out to subscribers, possibly running on other
+// instances of the server.
+package pubsub
+
+import "context"
+
+type Broker interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+	// Subscribe delivers payloads published to topic after it returns. The
+	// channel is closed once ctx is done, or early if the subscriber falls
+	// too far behind and payloads were lost to it.
+	Subscribe(ctx context.Context, topic string) (<-chan []byte, error)
+}
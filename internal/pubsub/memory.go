@@ -0,0 +1,75 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind.
+// A subscriber that falls further behind is dropped: its channel is closed
+// so that it knows events were lost. Subscribers of events.Bus recover
+// them from its log.
+const subscriberBuffer = 10
+
+// MemoryBroker delivers events within a single process.
+type MemoryBroker struct {
+	mu          sync.Mutex
+	subscribers map[string][]chan []byte
+}
+
+func NewMemoryBroker() Broker {
+	return &MemoryBroker{subscribers: make(map[string][]chan []byte)}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, topic string, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, ch := range b.subscribers[topic] {
+		select {
+		case ch <- payload:
+		default:
+			b.remove(topic, ch)
+		}
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	ch := make(chan []byte, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[topic] = append(b.subscribers[topic], ch)
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(topic, ch)
+	}()
+
+	return ch, nil
+}
+
+// remove closes ch and drops it from the subscribers of topic, unless it
+// was dropped already. It must be called with b.mu held. The subscribers
+// are copied rather than shifted in place, as Publish may be ranging over
+// them.
+func (b *MemoryBroker) remove(topic string, ch chan []byte) {
+	subs := b.subscribers[topic]
+	for i, sub := range subs {
+		if sub != ch {
+			continue
+		}
+		subs = append(subs[:i:i], subs[i+1:]...)
+		if len(subs) == 0 {
+			delete(b.subscribers, topic)
+		} else {
+			b.subscribers[topic] = subs
+		}
+		close(ch)
+		return
+	}
+}
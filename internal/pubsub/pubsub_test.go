@@ -0,0 +1,166 @@
+package pubsub
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMemoryBroker(t *testing.T) {
+	testBroker(t, NewMemoryBroker())
+}
+
+// TestRedisBroker runs against the server at REDIS_TEST_ADDR, e.g.
+// localhost:6379.
+func TestRedisBroker(t *testing.T) {
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_TEST_ADDR is not set")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("failed to connect to redis: %v", err)
+	}
+
+	// Two brokers sharing a server stand in for two instances.
+	publisher, subscriber := NewRedisBroker(client), NewRedisBroker(client)
+	testBroker(t, publisher)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := subscriber.Subscribe(ctx, "cross-instance")
+	if err != nil {
+		t.Fatalf("Subscribe: unexpected error: %v", err)
+	}
+	if err := publisher.Publish(ctx, "cross-instance", []byte("hello")); err != nil {
+		t.Fatalf("Publish: unexpected error: %v", err)
+	}
+	expectEvent(t, events, "hello")
+
+	t.Run("shares one connection", func(t *testing.T) {
+		for range 3 {
+			if _, err := subscriber.Subscribe(ctx, "shared"); err != nil {
+				t.Fatalf("Subscribe: unexpected error: %v", err)
+			}
+		}
+		counts, err := client.PubSubNumSub(ctx, "shared").Result()
+		if err != nil {
+			t.Fatalf("PubSubNumSub: unexpected error: %v", err)
+		}
+		if counts["shared"] != 1 {
+			t.Fatalf("expected one Redis subscription, got %d", counts["shared"])
+		}
+	})
+
+	t.Run("subscribes again after the last subscriber leaves", func(t *testing.T) {
+		first, cancelFirst := context.WithCancel(ctx)
+		if _, err := subscriber.Subscribe(first, "again"); err != nil {
+			t.Fatalf("Subscribe: unexpected error: %v", err)
+		}
+		cancelFirst()
+
+		events, err := subscriber.Subscribe(ctx, "again")
+		if err != nil {
+			t.Fatalf("Subscribe: unexpected error: %v", err)
+		}
+		if err := publisher.Publish(ctx, "again", []byte("back")); err != nil {
+			t.Fatalf("Publish: unexpected error: %v", err)
+		}
+		expectEvent(t, events, "back")
+	})
+}
+
+func testBroker(t *testing.T, b Broker) {
+	t.Run("delivers to every subscriber of a topic", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		first, err := b.Subscribe(ctx, "post:1")
+		if err != nil {
+			t.Fatalf("Subscribe: unexpected error: %v", err)
+		}
+		second, err := b.Subscribe(ctx, "post:1")
+		if err != nil {
+			t.Fatalf("Subscribe: unexpected error: %v", err)
+		}
+		other, err := b.Subscribe(ctx, "post:2")
+		if err != nil {
+			t.Fatalf("Subscribe: unexpected error: %v", err)
+		}
+
+		if err := b.Publish(ctx, "post:1", []byte("comment")); err != nil {
+			t.Fatalf("Publish: unexpected error: %v", err)
+		}
+
+		expectEvent(t, first, "comment")
+		expectEvent(t, second, "comment")
+		select {
+		case got := <-other:
+			t.Fatalf("expected no event on another topic, got %q", got)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("closes the channel of a subscriber that falls behind", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		events, err := b.Subscribe(ctx, "post:4")
+		if err != nil {
+			t.Fatalf("Subscribe: unexpected error: %v", err)
+		}
+		for range subscriberBuffer + 1 {
+			if err := b.Publish(ctx, "post:4", []byte("comment")); err != nil {
+				t.Fatalf("Publish: unexpected error: %v", err)
+			}
+		}
+
+		for range subscriberBuffer {
+			expectEvent(t, events, "comment")
+		}
+		select {
+		case _, ok := <-events:
+			if ok {
+				t.Fatal("expected channel to be closed")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("channel was not closed")
+		}
+	})
+
+	t.Run("closes channel when context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		events, err := b.Subscribe(ctx, "post:3")
+		if err != nil {
+			t.Fatalf("Subscribe: unexpected error: %v", err)
+		}
+		cancel()
+
+		select {
+		case _, ok := <-events:
+			if ok {
+				t.Fatal("expected channel to be closed")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("channel was not closed")
+		}
+	})
+}
+
+func expectEvent(t *testing.T, events <-chan []byte, want string) {
+	t.Helper()
+	select {
+	case got := <-events:
+		if string(got) != want {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected event %q", want)
+	}
+}
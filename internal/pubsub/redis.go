@@ -0,0 +1,136 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBroker delivers events to every instance subscribed to the same
+// Redis server. All the subscriptions of an instance share one Redis
+// connection; payloads are fanned out to local subscribers by topic.
+type RedisBroker struct {
+	client *redis.Client
+	local  *MemoryBroker
+	pubsub *redis.PubSub
+
+	mu     sync.Mutex
+	topics map[string]*redisTopic
+	// pending holds, for every topic, the ready channel of every SUBSCRIBE
+	// sent and not yet confirmed, in the order they were sent.
+	pending map[string][]chan struct{}
+}
+
+// redisTopic is a topic the connection is subscribed to.
+type redisTopic struct {
+	subscribers int
+	// ready is closed once Redis confirms the subscription.
+	ready chan struct{}
+}
+
+func NewRedisBroker(client *redis.Client) Broker {
+	b := &RedisBroker{
+		client:  client,
+		local:   &MemoryBroker{subscribers: make(map[string][]chan []byte)},
+		pubsub:  client.Subscribe(context.Background()),
+		topics:  make(map[string]*redisTopic),
+		pending: make(map[string][]chan struct{}),
+	}
+	go b.listen()
+	return b
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, topic string, payload []byte) error {
+	return b.client.Publish(ctx, topic, payload).Err()
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	subCtx, cancel := context.WithCancel(ctx)
+	ch, err := b.local.Subscribe(subCtx, topic)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	b.mu.Lock()
+	t, ok := b.topics[topic]
+	if !ok {
+		t = &redisTopic{ready: make(chan struct{})}
+		b.topics[topic] = t
+		b.pending[topic] = append(b.pending[topic], t.ready)
+		if err = b.pubsub.Subscribe(ctx, topic); err != nil {
+			b.pending[topic] = b.pending[topic][:len(b.pending[topic])-1]
+		}
+	}
+	t.subscribers++
+	b.mu.Unlock()
+
+	release := func() {
+		cancel()
+		b.release(topic)
+	}
+	if err != nil {
+		release()
+		return nil, err
+	}
+	// Wait for the subscription to be confirmed so that nothing published
+	// after Subscribe returns is missed.
+	select {
+	case <-t.ready:
+	case <-ctx.Done():
+		release()
+		return nil, ctx.Err()
+	}
+
+	go func() {
+		<-subCtx.Done()
+		b.release(topic)
+	}()
+	return ch, nil
+}
+
+// release drops a local subscriber of topic, unsubscribing the connection
+// once the last one is gone. Commands are sent under the lock so that
+// SUBSCRIBE and UNSUBSCRIBE of a topic reach Redis in the order the
+// subscriber counts changed.
+func (b *RedisBroker) release(topic string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topics[topic]
+	if t.subscribers--; t.subscribers > 0 {
+		return
+	}
+	delete(b.topics, topic)
+	_ = b.pubsub.Unsubscribe(context.Background(), topic)
+}
+
+func (b *RedisBroker) listen() {
+	for msg := range b.pubsub.ChannelWithSubscriptions() {
+		switch msg := msg.(type) {
+		case *redis.Message:
+			_ = b.local.Publish(context.Background(), msg.Channel, []byte(msg.Payload))
+		case *redis.Subscription:
+			if msg.Kind == "subscribe" {
+				b.confirm(msg.Channel)
+			}
+		}
+	}
+}
+
+// confirm wakes the subscriber waiting for the oldest SUBSCRIBE of topic.
+// Subscriptions the client restores after a reconnect confirm nothing.
+func (b *RedisBroker) confirm(topic string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	pending := b.pending[topic]
+	if len(pending) == 0 {
+		return
+	}
+	close(pending[0])
+	if len(pending) == 1 {
+		delete(b.pending, topic)
+	} else {
+		b.pending[topic] = pending[1:]
+	}
+}
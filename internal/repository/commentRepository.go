@@ -4,10 +4,35 @@ import (
 	"context"
 
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pagination"
 )
 
 type CommentRepository interface {
 	Create(ctx context.Context, comment *domain.Comment) error
+	Get(ctx context.Context, id string) (*domain.Comment, error)
+	// UpdateText replaces the text of a comment and marks it as edited.
+	UpdateText(ctx context.Context, id, text string) error
+	// SoftDelete replaces the text of a comment with CommentTombstone and
+	// marks it as deleted, keeping its replies attached.
+	SoftDelete(ctx context.Context, id string) error
+	SetLocked(ctx context.Context, id string, locked bool) error
+	// DeleteLeaf removes a comment if it has no replies and reports whether
+	// it did.
+	DeleteLeaf(ctx context.Context, id string) (bool, error)
 	GetByPostIDs(ctx context.Context, postId []string) ([]*domain.Comment, error)
 	GetByPostID(ctx context.Context, postId string) ([]*domain.Comment, error)
+	// GetRootsByPostID returns top-level comments of a post in the given
+	// order. A non-positive limit means no limit.
+	GetRootsByPostID(ctx context.Context, postId string, filter domain.CommentFilter, order domain.CommentOrder, limit, offset int) ([]*domain.Comment, error)
+	GetRootsPage(ctx context.Context, postId string, filter domain.CommentFilter, window pagination.Window) ([]*domain.Comment, error)
+	GetRootsByPostIDs(ctx context.Context, postIDs []string, order domain.CommentOrder) ([]*domain.Comment, error)
+	GetByParentIDs(ctx context.Context, parentIDs []string, order domain.CommentOrder) ([]*domain.Comment, error)
+	// GetDescendants returns the replies below parentIDs down to maxDepth
+	// levels, level by level and in the given order within a level.
+	GetDescendants(ctx context.Context, parentIDs []string, maxDepth int, order domain.CommentOrder) ([]*domain.Comment, error)
+	// SetVote records the vote of a user on a comment; VoteNone removes it.
+	SetVote(ctx context.Context, commentID, userID string, value domain.VoteValue) error
+	// GetVotes returns the votes of a user on the given comments, leaving
+	// out the ones the user has not voted on.
+	GetVotes(ctx context.Context, userID string, commentIDs []string) (map[string]domain.VoteValue, error)
 }
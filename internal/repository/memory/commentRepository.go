@@ -0,0 +1,351 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pagination"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/repository"
+)
+
+type CommentRepo struct {
+	store *Store
+}
+
+func NewCommentRepo(store *Store) repository.CommentRepository {
+	return &CommentRepo{store: store}
+}
+
+func (r *CommentRepo) Create(ctx context.Context, comment *domain.Comment) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.comments[comment.ID]; ok {
+		return domain.Conflict("comment already exists")
+	}
+	if _, ok := r.store.posts[comment.PostID]; !ok {
+		return domain.NotFound("post")
+	}
+	comment.Depth = 1
+	if comment.ParentID != nil {
+		parent, ok := r.store.comments[*comment.ParentID]
+		if !ok {
+			return domain.NotFound("parent comment")
+		}
+		comment.Depth = parent.comment.Depth + 1
+	}
+
+	comment.CreatedAt = time.Now().UTC()
+	comment.UpdatedAt = comment.CreatedAt
+	r.store.comments[comment.ID] = &commentRecord{
+		comment: *copyComment(comment),
+		votes:   make(map[string]domain.VoteValue),
+	}
+	if comment.ParentID != nil {
+		parent := r.store.comments[*comment.ParentID]
+		parent.replies = append(parent.replies, comment.ID)
+	}
+	return nil
+}
+
+func (r *CommentRepo) Get(ctx context.Context, id string) (*domain.Comment, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	rec, ok := r.store.comments[id]
+	if !ok {
+		return nil, domain.NotFound("comment")
+	}
+	return rec.snapshot(), nil
+}
+
+func (r *CommentRepo) UpdateText(ctx context.Context, id, text string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	rec, ok := r.store.comments[id]
+	if !ok {
+		return domain.NotFound("comment")
+	}
+	rec.comment.Text = text
+	rec.comment.Edited = true
+	rec.comment.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (r *CommentRepo) SoftDelete(ctx context.Context, id string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	rec, ok := r.store.comments[id]
+	if !ok {
+		return domain.NotFound("comment")
+	}
+	now := time.Now().UTC()
+	rec.comment.Text = domain.CommentTombstone
+	rec.comment.UpdatedAt = now
+	rec.comment.DeletedAt = &now
+	return nil
+}
+
+func (r *CommentRepo) SetLocked(ctx context.Context, id string, locked bool) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	rec, ok := r.store.comments[id]
+	if !ok {
+		return domain.NotFound("comment")
+	}
+	rec.comment.Locked = locked
+	return nil
+}
+
+func (r *CommentRepo) DeleteLeaf(ctx context.Context, id string) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	rec, ok := r.store.comments[id]
+	if !ok {
+		return false, domain.NotFound("comment")
+	}
+	if len(rec.replies) > 0 {
+		return false, nil
+	}
+
+	delete(r.store.comments, id)
+	if rec.comment.ParentID != nil {
+		parent := r.store.comments[*rec.comment.ParentID]
+		parent.replies = slices.DeleteFunc(parent.replies, func(replyID string) bool {
+			return replyID == id
+		})
+	}
+	return true, nil
+}
+
+func (r *CommentRepo) SetVote(ctx context.Context, commentID, userID string, value domain.VoteValue) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	rec, ok := r.store.comments[commentID]
+	if !ok {
+		return domain.NotFound("comment")
+	}
+	if value == domain.VoteNone {
+		delete(rec.votes, userID)
+	} else {
+		rec.votes[userID] = value
+	}
+	return nil
+}
+
+func (r *CommentRepo) GetVotes(ctx context.Context, userID string, commentIDs []string) (map[string]domain.VoteValue, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	votes := make(map[string]domain.VoteValue)
+	for _, id := range commentIDs {
+		rec, ok := r.store.comments[id]
+		if !ok {
+			continue
+		}
+		if v, ok := rec.votes[userID]; ok {
+			votes[id] = v
+		}
+	}
+	return votes, nil
+}
+
+func (r *CommentRepo) GetByPostID(ctx context.Context, postID string) ([]*domain.Comment, error) {
+	return r.GetByPostIDs(ctx, []string{postID})
+}
+
+func (r *CommentRepo) GetByPostIDs(ctx context.Context, postIDs []string) ([]*domain.Comment, error) {
+	if len(postIDs) == 0 {
+		return nil, nil
+	}
+
+	wanted := toSet(postIDs)
+	return r.filter(func(c *domain.Comment) bool {
+		_, ok := wanted[c.PostID]
+		return ok
+	}), nil
+}
+
+func (r *CommentRepo) GetRootsByPostID(ctx context.Context, postID string, filter domain.CommentFilter, order domain.CommentOrder, limit, offset int) ([]*domain.Comment, error) {
+	roots := r.filter(func(c *domain.Comment) bool {
+		return c.PostID == postID && c.ParentID == nil && matchCommentFilter(c, filter)
+	})
+	sortComments(roots, order)
+
+	if offset >= len(roots) {
+		return nil, nil
+	}
+	roots = roots[offset:]
+	if limit > 0 && limit < len(roots) {
+		roots = roots[:limit]
+	}
+	return roots, nil
+}
+
+func (r *CommentRepo) GetRootsPage(ctx context.Context, postID string, filter domain.CommentFilter, window pagination.Window) ([]*domain.Comment, error) {
+	roots := r.filter(func(c *domain.Comment) bool {
+		if c.PostID != postID || c.ParentID != nil || !matchCommentFilter(c, filter) {
+			return false
+		}
+		if window.After != nil && compareCursor(c, window.After) <= 0 {
+			return false
+		}
+		if window.Before != nil && compareCursor(c, window.Before) >= 0 {
+			return false
+		}
+		return true
+	})
+
+	if window.Limit < len(roots) {
+		if window.Backward {
+			roots = roots[len(roots)-window.Limit:]
+		} else {
+			roots = roots[:window.Limit]
+		}
+	}
+	return roots, nil
+}
+
+func (r *CommentRepo) GetRootsByPostIDs(ctx context.Context, postIDs []string, order domain.CommentOrder) ([]*domain.Comment, error) {
+	if len(postIDs) == 0 {
+		return nil, nil
+	}
+
+	wanted := toSet(postIDs)
+	roots := r.filter(func(c *domain.Comment) bool {
+		_, ok := wanted[c.PostID]
+		return ok && c.ParentID == nil
+	})
+	sortComments(roots, order)
+	return roots, nil
+}
+
+func (r *CommentRepo) GetByParentIDs(ctx context.Context, parentIDs []string, order domain.CommentOrder) ([]*domain.Comment, error) {
+	if len(parentIDs) == 0 {
+		return nil, nil
+	}
+
+	wanted := toSet(parentIDs)
+	replies := r.filter(func(c *domain.Comment) bool {
+		if c.ParentID == nil {
+			return false
+		}
+		_, ok := wanted[*c.ParentID]
+		return ok
+	})
+	sortComments(replies, order)
+	return replies, nil
+}
+
+func (r *CommentRepo) GetDescendants(ctx context.Context, parentIDs []string, maxDepth int, order domain.CommentOrder) ([]*domain.Comment, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var descendants []*domain.Comment
+	level := parentIDs
+	for depth := 1; depth <= maxDepth && len(level) > 0; depth++ {
+		var next []*domain.Comment
+		for _, id := range level {
+			rec, ok := r.store.comments[id]
+			if !ok {
+				continue
+			}
+			for _, replyID := range rec.replies {
+				next = append(next, r.store.comments[replyID].snapshot())
+			}
+		}
+		sort.Slice(next, func(i, j int) bool {
+			return compareComments(next[i], next[j]) < 0
+		})
+		sortComments(next, order)
+
+		level = level[:0:0]
+		for _, c := range next {
+			level = append(level, c.ID)
+		}
+		descendants = append(descendants, next...)
+	}
+	return descendants, nil
+}
+
+// filter returns copies of the matching comments ordered by (created_at, id).
+func (r *CommentRepo) filter(match func(c *domain.Comment) bool) []*domain.Comment {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var comments []*domain.Comment
+	for _, rec := range r.store.comments {
+		if match(&rec.comment) {
+			comments = append(comments, rec.snapshot())
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		return compareComments(comments[i], comments[j]) < 0
+	})
+	return comments
+}
+
+func compareComments(a, b *domain.Comment) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// sortComments reorders comments that are already sorted oldest first.
+func sortComments(comments []*domain.Comment, order domain.CommentOrder) {
+	var rank func(c *domain.Comment) float64
+	switch order {
+	case domain.CommentOrderNew:
+		slices.Reverse(comments)
+		return
+	case domain.CommentOrderTop:
+		rank = func(c *domain.Comment) float64 { return float64(c.Upvotes - c.Downvotes) }
+	case domain.CommentOrderBest:
+		rank = func(c *domain.Comment) float64 { return domain.WilsonScore(c.Upvotes, c.Downvotes) }
+	case domain.CommentOrderControversial:
+		rank = func(c *domain.Comment) float64 { return domain.Controversy(c.Upvotes, c.Downvotes) }
+	default:
+		return
+	}
+
+	// Stable, so that ties stay oldest first.
+	slices.SortStableFunc(comments, func(a, b *domain.Comment) int {
+		return cmp.Compare(rank(b), rank(a))
+	})
+}
+
+func matchCommentFilter(c *domain.Comment, filter domain.CommentFilter) bool {
+	if filter.CreatedAfter != nil && !c.CreatedAt.After(*filter.CreatedAfter) {
+		return false
+	}
+	if filter.CreatedBefore != nil && !c.CreatedAt.Before(*filter.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+func compareCursor(c *domain.Comment, cursor *pagination.Cursor) int {
+	if cmp := c.CreatedAt.Compare(cursor.CreatedAt); cmp != 0 {
+		return cmp
+	}
+	return strings.Compare(c.ID, cursor.ID)
+}
+
+func toSet(ids []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,15 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/repository"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/repository/repotest"
+)
+
+func TestRepositories(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) (repository.PostRepository, repository.CommentRepository) {
+		store := NewStore()
+		return NewPostRepo(store), NewCommentRepo(store)
+	})
+}
@@ -0,0 +1,199 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pagination"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/repository"
+)
+
+type PostRepo struct {
+	store *Store
+}
+
+func NewPostRepo(store *Store) repository.PostRepository {
+	return &PostRepo{store: store}
+}
+
+func (r *PostRepo) Create(ctx context.Context, post *domain.Post) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.posts[post.ID]; ok {
+		return domain.Conflict("post already exists")
+	}
+
+	post.Revision = 1
+	post.CreatedAt = time.Now().UTC()
+	post.UpdatedAt = post.CreatedAt
+	r.store.posts[post.ID] = &postRecord{post: *copyPost(post)}
+	return nil
+}
+
+func (r *PostRepo) Get(ctx context.Context, postId string) (*domain.Post, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	rec, ok := r.store.posts[postId]
+	if !ok {
+		return nil, domain.NotFound("post")
+	}
+	return copyPost(&rec.post), nil
+}
+
+func (r *PostRepo) GetList(ctx context.Context) ([]*domain.Post, error) {
+	posts := r.filter(func(p *domain.Post) bool { return true })
+	slices.Reverse(posts)
+	return posts, nil
+}
+
+func (r *PostRepo) GetByIDs(ctx context.Context, ids []string) ([]*domain.Post, error) {
+	return r.filter(func(p *domain.Post) bool { return slices.Contains(ids, p.ID) }), nil
+}
+
+func (r *PostRepo) SetFlag(ctx context.Context, postId string, flag bool) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	rec, ok := r.store.posts[postId]
+	if !ok {
+		return domain.NotFound("post")
+	}
+	rec.post.Flag = flag
+	return nil
+}
+
+func (r *PostRepo) Update(ctx context.Context, postId, title, content string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	rec, ok := r.store.posts[postId]
+	if !ok {
+		return domain.NotFound("post")
+	}
+	rec.revisions = append(rec.revisions, domain.PostRevision{
+		PostID:    postId,
+		Revision:  rec.post.Revision,
+		Title:     rec.post.Title,
+		Content:   rec.post.Content,
+		CreatedAt: rec.post.UpdatedAt,
+	})
+	rec.post.Title = title
+	rec.post.Content = content
+	rec.post.Revision++
+	rec.post.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (r *PostRepo) GetRevision(ctx context.Context, postId string, revision int) (*domain.PostRevision, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	if rec, ok := r.store.posts[postId]; ok {
+		for _, rev := range rec.revisions {
+			if rev.Revision == revision {
+				return &rev, nil
+			}
+		}
+	}
+	return nil, domain.NotFound("revision")
+}
+
+func (r *PostRepo) GetRevisionsByPostIDs(ctx context.Context, postIDs []string) ([]*domain.PostRevision, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var revisions []*domain.PostRevision
+	for _, id := range postIDs {
+		rec, ok := r.store.posts[id]
+		if !ok {
+			continue
+		}
+		for _, rev := range rec.revisions {
+			revisions = append(revisions, &rev)
+		}
+	}
+	return revisions, nil
+}
+
+func (r *PostRepo) GetPage(ctx context.Context, filter domain.PostFilter, order domain.PostOrder, window pagination.Window) ([]*domain.Post, error) {
+	posts := r.filter(func(p *domain.Post) bool {
+		return matchPostFilter(p, filter)
+	})
+	if order != domain.PostOrderOldest {
+		slices.Reverse(posts)
+	}
+
+	// position reports where p lies relative to cursor in the listing order.
+	position := func(p *domain.Post, cursor *pagination.Cursor) int {
+		cmp := p.CreatedAt.Compare(cursor.CreatedAt)
+		if cmp == 0 {
+			cmp = strings.Compare(p.ID, cursor.ID)
+		}
+		if order != domain.PostOrderOldest {
+			cmp = -cmp
+		}
+		return cmp
+	}
+
+	page := posts[:0]
+	for _, p := range posts {
+		if window.After != nil && position(p, window.After) <= 0 {
+			continue
+		}
+		if window.Before != nil && position(p, window.Before) >= 0 {
+			continue
+		}
+		page = append(page, p)
+	}
+
+	if window.Limit < len(page) {
+		if window.Backward {
+			page = page[len(page)-window.Limit:]
+		} else {
+			page = page[:window.Limit]
+		}
+	}
+	return page, nil
+}
+
+// filter returns copies of the matching posts ordered by (created_at, id).
+func (r *PostRepo) filter(match func(p *domain.Post) bool) []*domain.Post {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var posts []*domain.Post
+	for _, rec := range r.store.posts {
+		if match(&rec.post) {
+			posts = append(posts, copyPost(&rec.post))
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		if c := posts[i].CreatedAt.Compare(posts[j].CreatedAt); c != 0 {
+			return c < 0
+		}
+		return posts[i].ID < posts[j].ID
+	})
+	return posts
+}
+
+func matchPostFilter(p *domain.Post, filter domain.PostFilter) bool {
+	if filter.Author != nil && p.Author != *filter.Author {
+		return false
+	}
+	if filter.CommentsAllowed != nil && p.Flag != *filter.CommentsAllowed {
+		return false
+	}
+	if filter.CreatedAfter != nil && !p.CreatedAt.After(*filter.CreatedAfter) {
+		return false
+	}
+	if filter.CreatedBefore != nil && !p.CreatedAt.Before(*filter.CreatedBefore) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+)
+
+// Store is the shared in-process storage behind the memory repositories,
+// the same way *sql.DB is shared by the postgres ones.
+type Store struct {
+	mu       sync.RWMutex
+	posts    map[string]*postRecord
+	comments map[string]*commentRecord
+}
+
+type postRecord struct {
+	post      domain.Post
+	revisions []domain.PostRevision
+}
+
+type commentRecord struct {
+	comment domain.Comment
+	replies []string
+	votes   map[string]domain.VoteValue
+}
+
+// snapshot returns a copy of the stored comment that is safe to hand out.
+func (rec *commentRecord) snapshot() *domain.Comment {
+	c := copyComment(&rec.comment)
+	c.ReplyCount = len(rec.replies)
+	for _, v := range rec.votes {
+		switch v {
+		case domain.VoteUp:
+			c.Upvotes++
+		case domain.VoteDown:
+			c.Downvotes++
+		}
+	}
+	return c
+}
+
+func NewStore() *Store {
+	return &Store{
+		posts:    make(map[string]*postRecord),
+		comments: make(map[string]*commentRecord),
+	}
+}
+
+func copyPost(p *domain.Post) *domain.Post {
+	cp := *p
+	return &cp
+}
+
+func copyComment(c *domain.Comment) *domain.Comment {
+	cp := *c
+	if c.ParentID != nil {
+		parentID := *c.ParentID
+		cp.ParentID = &parentID
+	}
+	if c.DeletedAt != nil {
+		deletedAt := *c.DeletedAt
+		cp.DeletedAt = &deletedAt
+	}
+	cp.Children = nil
+	cp.HasMoreReplies = false
+	return &cp
+}
@@ -4,11 +4,25 @@ import (
 	"context"
 
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pagination"
 )
 
 type PostRepository interface {
 	Create(ctx context.Context, post *domain.Post) error
 	Get(ctx context.Context, postId string) (*domain.Post, error)
 	GetList(ctx context.Context) ([]*domain.Post, error)
+	// GetByIDs returns the posts with the given ids in no particular order,
+	// leaving out the ones that do not exist.
+	GetByIDs(ctx context.Context, ids []string) ([]*domain.Post, error)
 	SetFlag(ctx context.Context, postId string, flag bool) error
+	// Update replaces the title and content of a post, keeping the current
+	// version as a revision.
+	Update(ctx context.Context, postId, title, content string) error
+	GetRevision(ctx context.Context, postId string, revision int) (*domain.PostRevision, error)
+	// GetRevisionsByPostIDs returns the previous versions of the posts,
+	// oldest first.
+	GetRevisionsByPostIDs(ctx context.Context, postIDs []string) ([]*domain.PostRevision, error)
+	// GetPage returns the posts matching filter in the given order using
+	// keyset pagination on (created_at, id).
+	GetPage(ctx context.Context, filter domain.PostFilter, order domain.PostOrder, window pagination.Window) ([]*domain.Post, error)
 }
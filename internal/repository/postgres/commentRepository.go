@@ -3,11 +3,58 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
 
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pagination"
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/repository"
 )
 
+// commentColumns is the select list of every comment query; the table
+// has to be aliased as c.
+const commentColumns = `
+	c.id, c.post_id, c.parent_id, c.author, c.text, c.created_at,
+	c.updated_at, c.edited, c.deleted_at, c.upvotes, c.downvotes, c.depth, c.locked,
+	(SELECT count(*) FROM comments r WHERE r.parent_id = c.id) AS reply_count
+`
+
+// Ranking expressions matching domain.WilsonScore and domain.Controversy.
+const (
+	wilsonScore = `
+		CASE WHEN c.upvotes + c.downvotes = 0 THEN 0 ELSE
+			(c.upvotes::float8 / (c.upvotes + c.downvotes)
+				+ 1.9208 / (c.upvotes + c.downvotes)
+				- 1.96 * sqrt(c.upvotes::float8 * c.downvotes / (c.upvotes + c.downvotes) + 0.9604)
+					/ (c.upvotes + c.downvotes))
+			/ (1 + 3.8416 / (c.upvotes + c.downvotes))
+		END`
+	controversy = `
+		CASE WHEN c.upvotes = 0 OR c.downvotes = 0 THEN 0 ELSE
+			power((c.upvotes + c.downvotes)::float8,
+				LEAST(c.upvotes, c.downvotes)::float8 / GREATEST(c.upvotes, c.downvotes))
+		END`
+)
+
+// commentOrderBy returns the ORDER BY list for order, breaking ties oldest
+// first.
+func commentOrderBy(order domain.CommentOrder) string {
+	const oldest = "c.created_at ASC, c.id ASC"
+	switch order {
+	case domain.CommentOrderNew:
+		return "c.created_at DESC, c.id DESC"
+	case domain.CommentOrderTop:
+		return "c.upvotes - c.downvotes DESC, " + oldest
+	case domain.CommentOrderBest:
+		return wilsonScore + " DESC, " + oldest
+	case domain.CommentOrderControversial:
+		return controversy + " DESC, " + oldest
+	default:
+		return oldest
+	}
+}
+
 type CommentRepo struct {
 	db *sql.DB
 }
@@ -16,64 +63,335 @@ func NewCommentRepo(db *sql.DB) repository.CommentRepository {
 	return &CommentRepo{db: db}
 }
 
-func (r *CommentRepo) Create(ctx context.Context, comment *domain.Comment) error {
+func (r *CommentRepo) Create(ctx context.Context, comment *domain.Comment) (err error) {
+	ctx, done := observe(ctx, "comment_create")
+	defer done(&err)
 	query := `
-		INSERT INTO comments (id, post_id, parent_id, author, text)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO comments (id, post_id, parent_id, author, text, depth)
+		VALUES ($1, $2, $3, $4, $5, COALESCE((SELECT depth + 1 FROM comments WHERE id = $3), 1))
+		RETURNING depth, created_at, updated_at
 	`
-	_, err := r.db.ExecContext(ctx, query,
+	err = r.db.QueryRowContext(ctx, query,
 		comment.ID,
 		comment.PostID,
 		comment.ParentID,
 		comment.Author,
 		comment.Text,
-	)
-	return err
+	).Scan(&comment.Depth, &comment.CreatedAt, &comment.UpdatedAt)
+	return mapError(err, "comment")
 }
 
-func (r *CommentRepo) GetByPostID(ctx context.Context, postID string) ([]*domain.Comment, error) {
+func (r *CommentRepo) Get(ctx context.Context, id string) (_ *domain.Comment, err error) {
+	ctx, done := observe(ctx, "comment_get")
+	defer done(&err)
+	query := `SELECT ` + commentColumns + `
+		FROM comments c
+		WHERE c.id = $1
+	`
+	comments, err := r.query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(comments) == 0 {
+		return nil, domain.NotFound("comment")
+	}
+	return comments[0], nil
+}
+
+func (r *CommentRepo) UpdateText(ctx context.Context, id, text string) (err error) {
+	ctx, done := observe(ctx, "comment_update_text")
+	defer done(&err)
 	query := `
-		SELECT id, post_id, parent_id, author, text
-		FROM comments
-		WHERE post_id = $1
-		ORDER BY created_at ASC
+		UPDATE comments
+		SET text = $2, edited = TRUE, updated_at = NOW()
+		WHERE id = $1
+	`
+	return r.exec(ctx, query, id, text)
+}
+
+func (r *CommentRepo) SoftDelete(ctx context.Context, id string) (err error) {
+	ctx, done := observe(ctx, "comment_soft_delete")
+	defer done(&err)
+	query := `
+		UPDATE comments
+		SET text = $2, updated_at = NOW(), deleted_at = NOW()
+		WHERE id = $1
+	`
+	return r.exec(ctx, query, id, domain.CommentTombstone)
+}
+
+func (r *CommentRepo) SetLocked(ctx context.Context, id string, locked bool) (err error) {
+	ctx, done := observe(ctx, "comment_set_locked")
+	defer done(&err)
+	query := `
+		UPDATE comments
+		SET locked = $2
+		WHERE id = $1
+	`
+	return r.exec(ctx, query, id, locked)
+}
+
+func (r *CommentRepo) DeleteLeaf(ctx context.Context, id string) (_ bool, err error) {
+	ctx, done := observe(ctx, "comment_delete_leaf")
+	defer done(&err)
+	query := `
+		DELETE FROM comments c
+		WHERE c.id = $1
+		  AND NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)
+	`
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n > 0 {
+		return true, nil
+	}
+
+	// Nothing deleted: either the comment has replies or it does not exist.
+	if _, err := r.Get(ctx, id); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+func (r *CommentRepo) SetVote(ctx context.Context, commentID, userID string, value domain.VoteValue) (err error) {
+	ctx, done := observe(ctx, "comment_set_vote")
+	defer done(&err)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the comment so that concurrent votes are counted one at a time.
+	var id string
+	err = tx.QueryRowContext(ctx, `SELECT id FROM comments WHERE id = $1 FOR UPDATE`, commentID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.NotFound("comment")
+	}
+	if err != nil {
+		return err
+	}
+
+	if value == domain.VoteNone {
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM comment_votes
+			WHERE comment_id = $1 AND user_id = $2
+		`, commentID, userID)
+	} else {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO comment_votes (comment_id, user_id, value)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (comment_id, user_id) DO UPDATE SET value = EXCLUDED.value
+		`, commentID, userID, int(value))
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE comments
+		SET upvotes = (SELECT count(*) FROM comment_votes WHERE comment_id = $1 AND value = 1),
+		    downvotes = (SELECT count(*) FROM comment_votes WHERE comment_id = $1 AND value = -1)
+		WHERE id = $1
+	`, commentID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *CommentRepo) GetVotes(ctx context.Context, userID string, commentIDs []string) (_ map[string]domain.VoteValue, err error) {
+	ctx, done := observe(ctx, "comment_get_votes")
+	defer done(&err)
+	votes := make(map[string]domain.VoteValue)
+	if len(commentIDs) == 0 {
+		return votes, nil
+	}
+
+	query := `
+		SELECT comment_id, value
+		FROM comment_votes
+		WHERE user_id = $1 AND comment_id = ANY($2)
 	`
-	rows, err := r.db.QueryContext(ctx, query, postID)
+	rows, err := r.db.QueryContext(ctx, query, userID, commentIDs)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var comments []*domain.Comment
 	for rows.Next() {
-		c := &domain.Comment{}
-		if err := rows.Scan(
-			&c.ID,
-			&c.PostID,
-			&c.ParentID,
-			&c.Author,
-			&c.Text,
-		); err != nil {
+		var (
+			id    string
+			value int
+		)
+		if err := rows.Scan(&id, &value); err != nil {
 			return nil, err
 		}
-		comments = append(comments, c)
+		votes[id] = domain.VoteValue(value)
+	}
+
+	return votes, rows.Err()
+}
+
+func (r *CommentRepo) exec(ctx context.Context, query string, args ...any) error {
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return mapError(err, "comment")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.NotFound("comment")
+	}
+	return nil
+}
+
+func (r *CommentRepo) GetByPostID(ctx context.Context, postID string) (_ []*domain.Comment, err error) {
+	ctx, done := observe(ctx, "comment_get_by_post_id")
+	defer done(&err)
+	query := `SELECT ` + commentColumns + `
+		FROM comments c
+		WHERE post_id = $1
+		ORDER BY created_at ASC, id ASC
+	`
+	return r.query(ctx, query, postID)
+}
+
+func (r *CommentRepo) GetByPostIDs(ctx context.Context, postIDs []string) (_ []*domain.Comment, err error) {
+	ctx, done := observe(ctx, "comment_get_by_post_ids")
+	defer done(&err)
+	if len(postIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `SELECT ` + commentColumns + `
+		FROM comments c
+		WHERE post_id = ANY($1)
+		ORDER BY created_at ASC, id ASC
+	`
+	return r.query(ctx, query, postIDs)
+}
+
+func (r *CommentRepo) GetRootsByPostID(ctx context.Context, postID string, filter domain.CommentFilter, order domain.CommentOrder, limit, offset int) (_ []*domain.Comment, err error) {
+	ctx, done := observe(ctx, "comment_get_roots_by_post_id")
+	defer done(&err)
+	query := `SELECT ` + commentColumns + `
+		FROM comments c
+		WHERE post_id = $1 AND parent_id IS NULL
+	`
+	args := []any{postID}
+	query, args = filterComments(query, args, filter)
+
+	var limitArg any
+	if limit > 0 {
+		limitArg = limit
 	}
+	args = append(args, limitArg, offset)
+	query += fmt.Sprintf(" ORDER BY %s LIMIT $%d OFFSET $%d", commentOrderBy(order), len(args)-1, len(args))
+	return r.query(ctx, query, args...)
+}
+
+func (r *CommentRepo) GetRootsPage(ctx context.Context, postID string, filter domain.CommentFilter, window pagination.Window) (_ []*domain.Comment, err error) {
+	ctx, done := observe(ctx, "comment_get_roots_page")
+	defer done(&err)
+	query := `SELECT ` + commentColumns + `
+		FROM comments c
+		WHERE post_id = $1 AND parent_id IS NULL
+	`
+	args := []any{postID}
+	query, args = filterComments(query, args, filter)
+	if window.After != nil {
+		args = append(args, window.After.CreatedAt, window.After.ID)
+		query += fmt.Sprintf(" AND (created_at, id) > ($%d, $%d)", len(args)-1, len(args))
+	}
+	if window.Before != nil {
+		args = append(args, window.Before.CreatedAt, window.Before.ID)
+		query += fmt.Sprintf(" AND (created_at, id) < ($%d, $%d)", len(args)-1, len(args))
+	}
+	if window.Backward {
+		query += " ORDER BY created_at DESC, id DESC"
+	} else {
+		query += " ORDER BY created_at ASC, id ASC"
+	}
+	args = append(args, window.Limit)
+	query += fmt.Sprintf(" LIMIT $%d", len(args))
 
+	comments, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if window.Backward {
+		slices.Reverse(comments)
+	}
 	return comments, nil
 }
 
-func (r *CommentRepo) GetByPostIDs(ctx context.Context, postIDs []string) ([]*domain.Comment, error) {
+func (r *CommentRepo) GetRootsByPostIDs(ctx context.Context, postIDs []string, order domain.CommentOrder) (_ []*domain.Comment, err error) {
+	ctx, done := observe(ctx, "comment_get_roots_by_post_ids")
+	defer done(&err)
 	if len(postIDs) == 0 {
 		return nil, nil
 	}
 
+	query := `SELECT ` + commentColumns + `
+		FROM comments c
+		WHERE post_id = ANY($1) AND parent_id IS NULL
+		ORDER BY ` + commentOrderBy(order) + `
+	`
+	return r.query(ctx, query, postIDs)
+}
+
+func (r *CommentRepo) GetByParentIDs(ctx context.Context, parentIDs []string, order domain.CommentOrder) (_ []*domain.Comment, err error) {
+	ctx, done := observe(ctx, "comment_get_by_parent_ids")
+	defer done(&err)
+	if len(parentIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `SELECT ` + commentColumns + `
+		FROM comments c
+		WHERE parent_id = ANY($1)
+		ORDER BY ` + commentOrderBy(order) + `
+	`
+	return r.query(ctx, query, parentIDs)
+}
+
+func (r *CommentRepo) GetDescendants(ctx context.Context, parentIDs []string, maxDepth int, order domain.CommentOrder) (_ []*domain.Comment, err error) {
+	ctx, done := observe(ctx, "comment_get_descendants")
+	defer done(&err)
+	if len(parentIDs) == 0 || maxDepth <= 0 {
+		return nil, nil
+	}
+
 	query := `
-		SELECT id, post_id, parent_id, author, text
-		FROM comments
-		WHERE post_id = ANY($1)
-		ORDER BY created_at ASC
+		WITH RECURSIVE thread AS (
+			SELECT id, created_at, 1 AS level
+			FROM comments
+			WHERE parent_id = ANY($1)
+			UNION ALL
+			SELECT ch.id, ch.created_at, t.level + 1
+			FROM comments ch
+			JOIN thread t ON ch.parent_id = t.id
+			WHERE t.level < $2
+		)
+		SELECT ` + commentColumns + `
+		FROM thread t
+		JOIN comments c ON c.id = t.id
+		ORDER BY t.level ASC, ` + commentOrderBy(order) + `
 	`
-	rows, err := r.db.QueryContext(ctx, query, postIDs)
+	return r.query(ctx, query, parentIDs, maxDepth)
+}
+
+func (r *CommentRepo) query(ctx context.Context, query string, args ...any) ([]*domain.Comment, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -88,11 +406,34 @@ func (r *CommentRepo) GetByPostIDs(ctx context.Context, postIDs []string) ([]*do
 			&c.ParentID,
 			&c.Author,
 			&c.Text,
+			&c.CreatedAt,
+			&c.UpdatedAt,
+			&c.Edited,
+			&c.DeletedAt,
+			&c.Upvotes,
+			&c.Downvotes,
+			&c.Depth,
+			&c.Locked,
+			&c.ReplyCount,
 		); err != nil {
 			return nil, err
 		}
 		comments = append(comments, c)
 	}
 
-	return comments, nil
+	return comments, rows.Err()
+}
+
+// filterComments appends the conditions of filter to a query that already
+// has a WHERE clause.
+func filterComments(query string, args []any, filter domain.CommentFilter) (string, []any) {
+	if filter.CreatedAfter != nil {
+		args = append(args, *filter.CreatedAfter)
+		query += fmt.Sprintf(" AND c.created_at > $%d", len(args))
+	}
+	if filter.CreatedBefore != nil {
+		args = append(args, *filter.CreatedBefore)
+		query += fmt.Sprintf(" AND c.created_at < $%d", len(args))
+	}
+	return query, args
 }
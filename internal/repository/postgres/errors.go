@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+)
+
+// mapError turns constraint violations on entity into domain errors and
+// returns other errors unchanged.
+func mapError(err error, entity string) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch pqErr.Code.Name() {
+	case "unique_violation":
+		return domain.Conflict(entity + " already exists")
+	case "foreign_key_violation":
+		switch {
+		case strings.Contains(pqErr.Constraint, "parent_id"):
+			return domain.NotFound("parent comment")
+		case strings.Contains(pqErr.Constraint, "post_id"):
+			return domain.NotFound("post")
+		}
+		return domain.NotFound(entity)
+	case "check_violation":
+		return domain.Validation(entity + " is invalid: " + pqErr.Constraint)
+	}
+	return err
+}
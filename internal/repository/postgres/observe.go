@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/metrics"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("postgres")
+
+// observe times a repository method in the query duration histogram and a
+// client span. It returns the context of the span and the function that
+// ends both, meant to be deferred with the error the method returns.
+func observe(ctx context.Context, query string) (context.Context, func(err *error)) {
+	ctx, span := tracer.Start(ctx, "postgres."+query,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "postgresql"),
+			attribute.String("db.operation.name", query),
+		),
+	)
+	done := metrics.ObserveQuery(query)
+	return ctx, func(err *error) {
+		done()
+		tracing.End(span, err)
+	}
+}
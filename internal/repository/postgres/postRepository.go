@@ -4,11 +4,17 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pagination"
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/repository"
 )
 
+const postColumns = `id, title, content, author, comments_allowed, revision, created_at, updated_at`
+
 type PostRepo struct {
 	db *sql.DB
 }
@@ -17,95 +23,291 @@ func NewPostRepo(db *sql.DB) repository.PostRepository {
 	return &PostRepo{db: db}
 }
 
-func (r *PostRepo) Create(ctx context.Context, post *domain.Post) error {
+func (r *PostRepo) Create(ctx context.Context, post *domain.Post) (err error) {
+	ctx, done := observe(ctx, "post_create")
+	defer done(&err)
 	query := `
 		INSERT INTO posts (id, title, content, author, comments_allowed)
 		VALUES ($1, $2, $3, $4, $5)
+		RETURNING revision, created_at, updated_at
 	`
-	_, err := r.db.ExecContext(ctx, query,
+	err = r.db.QueryRowContext(ctx, query,
 		post.ID,
 		post.Title,
 		post.Content,
 		post.Author,
 		post.Flag,
-	)
-	return err
+	).Scan(&post.Revision, &post.CreatedAt, &post.UpdatedAt)
+	return mapError(err, "post")
 }
 
-func (r *PostRepo) Get(ctx context.Context, postId string) (*domain.Post, error) {
+func (r *PostRepo) Get(ctx context.Context, postId string) (_ *domain.Post, err error) {
+	ctx, done := observe(ctx, "post_get")
+	defer done(&err)
+	query := `SELECT ` + postColumns + ` FROM posts WHERE id = $1`
+	post, err := scanPost(r.db.QueryRowContext(ctx, query, postId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.NotFound("post")
+		}
+		return nil, err
+	}
+
+	return post, nil
+}
+
+func (r *PostRepo) GetList(ctx context.Context) (_ []*domain.Post, err error) {
+	ctx, done := observe(ctx, "post_get_list")
+	defer done(&err)
 	query := `
-		SELECT id, title, content, author, comments_allowed
+		SELECT ` + postColumns + `
 		FROM posts
-		WHERE id = $1
+		ORDER BY created_at DESC, id DESC
 	`
-	row := r.db.QueryRowContext(ctx, query, postId)
+	return r.query(ctx, query)
+}
 
-	post := &domain.Post{}
-	err := row.Scan(
-		&post.ID,
-		&post.Title,
-		&post.Content,
-		&post.Author,
-		&post.Flag,
+func (r *PostRepo) GetByIDs(ctx context.Context, ids []string) (_ []*domain.Post, err error) {
+	ctx, done := observe(ctx, "post_get_by_ids")
+	defer done(&err)
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := `SELECT ` + postColumns + ` FROM posts WHERE id = ANY($1)`
+	return r.query(ctx, query, ids)
+}
+
+func (r *PostRepo) SetFlag(ctx context.Context, postId string, flag bool) (err error) {
+	ctx, done := observe(ctx, "post_set_flag")
+	defer done(&err)
+	query := `
+		UPDATE posts
+		SET comments_allowed = $1
+		WHERE id = $2
+	`
+	res, err := r.db.ExecContext(ctx, query, flag, postId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.NotFound("post")
+	}
+
+	return nil
+}
+
+func (r *PostRepo) Update(ctx context.Context, postId, title, content string) (err error) {
+	ctx, done := observe(ctx, "post_update")
+	defer done(&err)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the post so that concurrent edits get consecutive revisions.
+	var rev domain.PostRevision
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, revision, title, content, updated_at
+		FROM posts
+		WHERE id = $1
+		FOR UPDATE
+	`, postId).Scan(&rev.PostID, &rev.Revision, &rev.Title, &rev.Content, &rev.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.NotFound("post")
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO post_revisions (post_id, revision, title, content, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, rev.PostID, rev.Revision, rev.Title, rev.Content, rev.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE posts
+		SET title = $2, content = $3, revision = revision + 1, updated_at = NOW()
+		WHERE id = $1
+	`, postId, title, content)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *PostRepo) GetRevision(ctx context.Context, postId string, revision int) (_ *domain.PostRevision, err error) {
+	ctx, done := observe(ctx, "post_get_revision")
+	defer done(&err)
+	query := `
+		SELECT post_id, revision, title, content, created_at
+		FROM post_revisions
+		WHERE post_id = $1 AND revision = $2
+	`
+	rev := &domain.PostRevision{}
+	err = r.db.QueryRowContext(ctx, query, postId, revision).Scan(
+		&rev.PostID,
+		&rev.Revision,
+		&rev.Title,
+		&rev.Content,
+		&rev.CreatedAt,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("post not found")
+			return nil, domain.NotFound("revision")
 		}
 		return nil, err
 	}
 
-	return post, nil
+	return rev, nil
 }
 
-func (r *PostRepo) GetList(ctx context.Context) ([]*domain.Post, error) {
+func (r *PostRepo) GetRevisionsByPostIDs(ctx context.Context, postIDs []string) (_ []*domain.PostRevision, err error) {
+	ctx, done := observe(ctx, "post_get_revisions_by_post_ids")
+	defer done(&err)
+	if len(postIDs) == 0 {
+		return nil, nil
+	}
+
 	query := `
-		SELECT id, title, content, author, comments_allowed
-		FROM posts
-		ORDER BY created_at DESC
+		SELECT post_id, revision, title, content, created_at
+		FROM post_revisions
+		WHERE post_id = ANY($1)
+		ORDER BY post_id, revision
 	`
-	rows, err := r.db.QueryContext(ctx, query)
+	rows, err := r.db.QueryContext(ctx, query, postIDs)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var posts []*domain.Post
+	var revisions []*domain.PostRevision
 	for rows.Next() {
-		post := &domain.Post{}
+		rev := &domain.PostRevision{}
 		if err := rows.Scan(
-			&post.ID,
-			&post.Title,
-			&post.Content,
-			&post.Author,
-			&post.Flag,
+			&rev.PostID,
+			&rev.Revision,
+			&rev.Title,
+			&rev.Content,
+			&rev.CreatedAt,
 		); err != nil {
 			return nil, err
 		}
-		posts = append(posts, post)
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (r *PostRepo) GetPage(ctx context.Context, filter domain.PostFilter, order domain.PostOrder, window pagination.Window) (_ []*domain.Post, err error) {
+	ctx, done := observe(ctx, "post_get_page")
+	defer done(&err)
+	var (
+		conds []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
 	}
 
+	if filter.Author != nil {
+		conds = append(conds, "author = "+arg(*filter.Author))
+	}
+	if filter.CommentsAllowed != nil {
+		conds = append(conds, "comments_allowed = "+arg(*filter.CommentsAllowed))
+	}
+	if filter.CreatedAfter != nil {
+		conds = append(conds, "created_at > "+arg(*filter.CreatedAfter))
+	}
+	if filter.CreatedBefore != nil {
+		conds = append(conds, "created_at < "+arg(*filter.CreatedBefore))
+	}
+
+	// In the newest-first listing "after" means older rows.
+	afterOp, beforeOp, dir := ">", "<", "ASC"
+	if order != domain.PostOrderOldest {
+		afterOp, beforeOp, dir = "<", ">", "DESC"
+	}
+	if window.After != nil {
+		conds = append(conds, fmt.Sprintf("(created_at, id) %s (%s, %s)",
+			afterOp, arg(window.After.CreatedAt), arg(window.After.ID)))
+	}
+	if window.Before != nil {
+		conds = append(conds, fmt.Sprintf("(created_at, id) %s (%s, %s)",
+			beforeOp, arg(window.Before.CreatedAt), arg(window.Before.ID)))
+	}
+	if window.Backward {
+		if dir == "ASC" {
+			dir = "DESC"
+		} else {
+			dir = "ASC"
+		}
+	}
+
+	query := `SELECT ` + postColumns + ` FROM posts`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY created_at %s, id %s LIMIT %s", dir, dir, arg(window.Limit))
+
+	posts, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if window.Backward {
+		slices.Reverse(posts)
+	}
 	return posts, nil
 }
 
-func (r *PostRepo) SetFlag(ctx context.Context, postId string, flag bool) error {
-	query := `
-		UPDATE posts
-		SET comments_allowed = $1
-		WHERE id = $2
-	`
-	res, err := r.db.ExecContext(ctx, query, flag, postId)
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPost(row rowScanner) (*domain.Post, error) {
+	post := &domain.Post{}
+	err := row.Scan(
+		&post.ID,
+		&post.Title,
+		&post.Content,
+		&post.Author,
+		&post.Flag,
+		&post.Revision,
+		&post.CreatedAt,
+		&post.UpdatedAt,
+	)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return post, nil
+}
 
-	rows, err := res.RowsAffected()
+func (r *PostRepo) query(ctx context.Context, query string, args ...any) ([]*domain.Post, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if rows == 0 {
-		return errors.New("post not found")
+	defer rows.Close()
+
+	var posts []*domain.Post
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
 	}
 
-	return nil
+	return posts, rows.Err()
 }
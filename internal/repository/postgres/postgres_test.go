@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/repository"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/repository/repotest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The suite runs against a migrated database given in POSTGRES_TEST_DSN.
+// Every test truncates the tables, so never point it at real data.
+func TestRepositories(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open postgres connection: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Fatalf("failed to ping postgres: %v", err)
+	}
+
+	repotest.Run(t, func(t *testing.T) (repository.PostRepository, repository.CommentRepository) {
+		if _, err := db.Exec(`TRUNCATE comment_votes, comments, post_revisions, posts`); err != nil {
+			t.Fatalf("failed to truncate tables: %v", err)
+		}
+		return NewPostRepo(db), NewCommentRepo(db)
+	})
+}
+
+func TestObserve(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	ctx, done := observe(context.Background(), "post_get")
+	err := errors.New("connection refused")
+	done(&err)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one ended span, got %d", len(spans))
+	}
+	if got := trace.SpanContextFromContext(ctx); got.SpanID() != spans[0].SpanContext().SpanID() {
+		t.Fatal("expected the returned context to carry the query span")
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Fatalf("expected the failed query to mark the span, got %v", spans[0].Status())
+	}
+}
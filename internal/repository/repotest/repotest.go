@@ -0,0 +1,960 @@
+// Package repotest holds the conformance suite every storage backend
+// has to pass. Backends call Run from their own tests with a Factory that
+// hands out empty repositories.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pagination"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/repository"
+)
+
+// Factory returns a pair of repositories backed by the same empty storage.
+type Factory func(t *testing.T) (repository.PostRepository, repository.CommentRepository)
+
+func Run(t *testing.T, newRepos Factory) {
+	t.Run("PostRepository", func(t *testing.T) { testPostRepository(t, newRepos) })
+	t.Run("CommentRepository", func(t *testing.T) { testCommentRepository(t, newRepos) })
+}
+
+func testPostRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	t.Run("create and get", func(t *testing.T) {
+		posts, _ := newRepos(t)
+		want := newPost("title")
+		mustCreatePost(t, posts, want)
+
+		got, err := posts.Get(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("Get: unexpected error: %v", err)
+		}
+		if got.ID != want.ID || got.Title != want.Title || got.Content != want.Content ||
+			got.Author != want.Author || got.Flag != want.Flag {
+			t.Fatalf("Get: got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("get not found", func(t *testing.T) {
+		posts, _ := newRepos(t)
+		got, err := posts.Get(ctx, uuid.NewString())
+		if !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("Get: expected ErrNotFound for unknown post, got %v", err)
+		}
+		if got != nil {
+			t.Fatalf("Get: expected nil post, got %+v", got)
+		}
+	})
+
+	t.Run("list is newest first", func(t *testing.T) {
+		posts, _ := newRepos(t)
+		first := newPost("first")
+		second := newPost("second")
+		third := newPost("third")
+		mustCreatePost(t, posts, first)
+		mustCreatePost(t, posts, second)
+		mustCreatePost(t, posts, third)
+
+		got, err := posts.GetList(ctx)
+		if err != nil {
+			t.Fatalf("GetList: unexpected error: %v", err)
+		}
+		assertPostIDs(t, got, third.ID, second.ID, first.ID)
+	})
+
+	t.Run("list empty", func(t *testing.T) {
+		posts, _ := newRepos(t)
+		got, err := posts.GetList(ctx)
+		if err != nil {
+			t.Fatalf("GetList: unexpected error: %v", err)
+		}
+		if len(got) != 0 {
+			t.Fatalf("GetList: expected no posts, got %d", len(got))
+		}
+	})
+
+	t.Run("get by ids", func(t *testing.T) {
+		posts, _ := newRepos(t)
+		first := newPost("first")
+		second := newPost("second")
+		third := newPost("third")
+		mustCreatePost(t, posts, first)
+		mustCreatePost(t, posts, second)
+		mustCreatePost(t, posts, third)
+
+		got, err := posts.GetByIDs(ctx, []string{third.ID, first.ID, uuid.NewString()})
+		if err != nil {
+			t.Fatalf("GetByIDs: unexpected error: %v", err)
+		}
+		slices.SortFunc(got, func(a, b *domain.Post) int { return a.CreatedAt.Compare(b.CreatedAt) })
+		assertPostIDs(t, got, first.ID, third.ID)
+
+		got, err = posts.GetByIDs(ctx, nil)
+		if err != nil {
+			t.Fatalf("GetByIDs: unexpected error: %v", err)
+		}
+		if len(got) != 0 {
+			t.Fatalf("GetByIDs: expected no posts, got %d", len(got))
+		}
+	})
+
+	t.Run("page", func(t *testing.T) {
+		posts, _ := newRepos(t)
+		a := newPost("a")
+		a.Author = "alice"
+		b := newPost("b")
+		b.Author = "bob"
+		b.Flag = false
+		c := newPost("c")
+		c.Author = "alice"
+		d := newPost("d")
+		d.Author = "alice"
+		for _, p := range []*domain.Post{a, b, c, d} {
+			mustCreatePost(t, posts, p)
+			if p.CreatedAt.IsZero() {
+				t.Fatal("Create: expected created at to be set")
+			}
+		}
+
+		cursor := func(p *domain.Post) *pagination.Cursor {
+			return &pagination.Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+		}
+		alice, disabled := "alice", false
+		cases := []struct {
+			name   string
+			filter domain.PostFilter
+			order  domain.PostOrder
+			window pagination.Window
+			want   []string
+		}{
+			{"newest", domain.PostFilter{}, domain.PostOrderNewest, pagination.Window{Limit: 2}, []string{d.ID, c.ID}},
+			{"newest after", domain.PostFilter{}, domain.PostOrderNewest, pagination.Window{After: cursor(c), Limit: 5}, []string{b.ID, a.ID}},
+			{"newest last", domain.PostFilter{}, domain.PostOrderNewest, pagination.Window{Limit: 2, Backward: true}, []string{b.ID, a.ID}},
+			{"newest before", domain.PostFilter{}, domain.PostOrderNewest, pagination.Window{Before: cursor(b), Limit: 5, Backward: true}, []string{d.ID, c.ID}},
+			{"oldest", domain.PostFilter{}, domain.PostOrderOldest, pagination.Window{Limit: 2}, []string{a.ID, b.ID}},
+			{"oldest after", domain.PostFilter{}, domain.PostOrderOldest, pagination.Window{After: cursor(b), Limit: 5}, []string{c.ID, d.ID}},
+			{"author", domain.PostFilter{Author: &alice}, domain.PostOrderNewest, pagination.Window{Limit: 5}, []string{d.ID, c.ID, a.ID}},
+			{"comments allowed", domain.PostFilter{CommentsAllowed: &disabled}, domain.PostOrderNewest, pagination.Window{Limit: 5}, []string{b.ID}},
+			{"created after", domain.PostFilter{CreatedAfter: &b.CreatedAt}, domain.PostOrderNewest, pagination.Window{Limit: 5}, []string{d.ID, c.ID}},
+			{"created before", domain.PostFilter{CreatedBefore: &c.CreatedAt}, domain.PostOrderNewest, pagination.Window{Limit: 5}, []string{b.ID, a.ID}},
+		}
+		for _, tc := range cases {
+			got, err := posts.GetPage(ctx, tc.filter, tc.order, tc.window)
+			if err != nil {
+				t.Fatalf("GetPage(%s): unexpected error: %v", tc.name, err)
+			}
+			assertPostIDs(t, got, tc.want...)
+		}
+	})
+
+	t.Run("set flag", func(t *testing.T) {
+		posts, _ := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+
+		if err := posts.SetFlag(ctx, post.ID, false); err != nil {
+			t.Fatalf("SetFlag: unexpected error: %v", err)
+		}
+		got, err := posts.Get(ctx, post.ID)
+		if err != nil {
+			t.Fatalf("Get: unexpected error: %v", err)
+		}
+		if got.Flag {
+			t.Fatal("SetFlag: expected comments to be disabled")
+		}
+
+		if err := posts.SetFlag(ctx, post.ID, true); err != nil {
+			t.Fatalf("SetFlag: unexpected error: %v", err)
+		}
+		got, err = posts.Get(ctx, post.ID)
+		if err != nil {
+			t.Fatalf("Get: unexpected error: %v", err)
+		}
+		if !got.Flag {
+			t.Fatal("SetFlag: expected comments to be enabled")
+		}
+	})
+
+	t.Run("set flag not found", func(t *testing.T) {
+		posts, _ := newRepos(t)
+		if err := posts.SetFlag(ctx, uuid.NewString(), false); !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("SetFlag: expected ErrNotFound for unknown post, got %v", err)
+		}
+	})
+
+	t.Run("update keeps revisions", func(t *testing.T) {
+		posts, _ := newRepos(t)
+		post := newPost("v1")
+		other := newPost("other")
+		mustCreatePost(t, posts, post)
+		mustCreatePost(t, posts, other)
+		if post.Revision != 1 {
+			t.Fatalf("Create: expected revision 1, got %d", post.Revision)
+		}
+
+		if err := posts.Update(ctx, post.ID, "v2", "content v2"); err != nil {
+			t.Fatalf("Update: unexpected error: %v", err)
+		}
+		tick()
+		if err := posts.Update(ctx, post.ID, "v3", "content v3"); err != nil {
+			t.Fatalf("Update: unexpected error: %v", err)
+		}
+
+		got, err := posts.Get(ctx, post.ID)
+		if err != nil {
+			t.Fatalf("Get: unexpected error: %v", err)
+		}
+		if got.Title != "v3" || got.Content != "content v3" || got.Revision != 3 {
+			t.Fatalf("Update: got %+v", got)
+		}
+		if !got.UpdatedAt.After(got.CreatedAt) {
+			t.Fatalf("Update: expected updated at %v after created at %v", got.UpdatedAt, got.CreatedAt)
+		}
+
+		revisions, err := posts.GetRevisionsByPostIDs(ctx, []string{post.ID, other.ID})
+		if err != nil {
+			t.Fatalf("GetRevisionsByPostIDs: unexpected error: %v", err)
+		}
+		if len(revisions) != 2 {
+			t.Fatalf("GetRevisionsByPostIDs: expected 2 revisions, got %d", len(revisions))
+		}
+		for i, want := range []string{"v1", "v2"} {
+			rev := revisions[i]
+			if rev.PostID != post.ID || rev.Revision != i+1 || rev.Title != want {
+				t.Fatalf("GetRevisionsByPostIDs[%d]: got %+v", i, rev)
+			}
+		}
+
+		rev, err := posts.GetRevision(ctx, post.ID, 1)
+		if err != nil {
+			t.Fatalf("GetRevision: unexpected error: %v", err)
+		}
+		if rev.Title != "v1" || rev.Content != "content of v1" {
+			t.Fatalf("GetRevision: got %+v", rev)
+		}
+		if _, err := posts.GetRevision(ctx, post.ID, 3); err == nil {
+			t.Fatal("GetRevision: expected error for the current revision")
+		}
+	})
+
+	t.Run("update not found", func(t *testing.T) {
+		posts, _ := newRepos(t)
+		if err := posts.Update(ctx, uuid.NewString(), "title", "content"); !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("Update: expected ErrNotFound for unknown post, got %v", err)
+		}
+	})
+}
+
+func testCommentRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	t.Run("create and get by post", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+
+		root := newComment(post.ID, nil, "root")
+		reply := newComment(post.ID, &root.ID, "reply")
+		mustCreateComment(t, comments, root)
+		mustCreateComment(t, comments, reply)
+
+		got, err := comments.GetByPostID(ctx, post.ID)
+		if err != nil {
+			t.Fatalf("GetByPostID: unexpected error: %v", err)
+		}
+		assertCommentIDs(t, got, root.ID, reply.ID)
+
+		if got[0].ParentID != nil {
+			t.Fatalf("expected root comment without parent, got %q", *got[0].ParentID)
+		}
+		if got[1].ParentID == nil || *got[1].ParentID != root.ID {
+			t.Fatalf("expected reply parent %q, got %v", root.ID, got[1].ParentID)
+		}
+		if got[1].PostID != post.ID || got[1].Author != reply.Author || got[1].Text != reply.Text {
+			t.Fatalf("GetByPostID: got %+v, want %+v", got[1], reply)
+		}
+	})
+
+	t.Run("get by post is oldest first", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+
+		first := newComment(post.ID, nil, "first")
+		second := newComment(post.ID, nil, "second")
+		third := newComment(post.ID, &first.ID, "third")
+		mustCreateComment(t, comments, first)
+		mustCreateComment(t, comments, second)
+		mustCreateComment(t, comments, third)
+
+		got, err := comments.GetByPostID(ctx, post.ID)
+		if err != nil {
+			t.Fatalf("GetByPostID: unexpected error: %v", err)
+		}
+		assertCommentIDs(t, got, first.ID, second.ID, third.ID)
+	})
+
+	t.Run("get by post without comments", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+
+		got, err := comments.GetByPostID(ctx, post.ID)
+		if err != nil {
+			t.Fatalf("GetByPostID: unexpected error: %v", err)
+		}
+		if len(got) != 0 {
+			t.Fatalf("GetByPostID: expected no comments, got %d", len(got))
+		}
+	})
+
+	t.Run("get by post ids", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		a := newPost("a")
+		b := newPost("b")
+		c := newPost("c")
+		mustCreatePost(t, posts, a)
+		mustCreatePost(t, posts, b)
+		mustCreatePost(t, posts, c)
+
+		onA := newComment(a.ID, nil, "on a")
+		onC := newComment(c.ID, nil, "on c")
+		onB := newComment(b.ID, nil, "on b")
+		mustCreateComment(t, comments, onA)
+		mustCreateComment(t, comments, onC)
+		mustCreateComment(t, comments, onB)
+
+		got, err := comments.GetByPostIDs(ctx, []string{a.ID, b.ID})
+		if err != nil {
+			t.Fatalf("GetByPostIDs: unexpected error: %v", err)
+		}
+		assertCommentIDs(t, got, onA.ID, onB.ID)
+	})
+
+	t.Run("get by no post ids", func(t *testing.T) {
+		_, comments := newRepos(t)
+		got, err := comments.GetByPostIDs(ctx, nil)
+		if err != nil {
+			t.Fatalf("GetByPostIDs: unexpected error: %v", err)
+		}
+		if len(got) != 0 {
+			t.Fatalf("GetByPostIDs: expected no comments, got %d", len(got))
+		}
+	})
+
+	t.Run("create sets created at", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+
+		comment := newComment(post.ID, nil, "text")
+		mustCreateComment(t, comments, comment)
+		if comment.CreatedAt.IsZero() {
+			t.Fatal("Create: expected created at to be set")
+		}
+	})
+
+	t.Run("roots by post with limit and offset", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+
+		r1 := newComment(post.ID, nil, "r1")
+		mustCreateComment(t, comments, r1)
+		mustCreateComment(t, comments, newComment(post.ID, &r1.ID, "reply"))
+		r2 := newComment(post.ID, nil, "r2")
+		r3 := newComment(post.ID, nil, "r3")
+		mustCreateComment(t, comments, r2)
+		mustCreateComment(t, comments, r3)
+
+		cases := []struct {
+			limit, offset int
+			want          []string
+		}{
+			{0, 0, []string{r1.ID, r2.ID, r3.ID}},
+			{2, 1, []string{r2.ID, r3.ID}},
+			{1, 0, []string{r1.ID}},
+			{0, 2, []string{r3.ID}},
+			{2, 5, nil},
+		}
+		for _, tc := range cases {
+			got, err := comments.GetRootsByPostID(ctx, post.ID, domain.CommentFilter{}, domain.CommentOrderOld, tc.limit, tc.offset)
+			if err != nil {
+				t.Fatalf("GetRootsByPostID(%d, %d): unexpected error: %v", tc.limit, tc.offset, err)
+			}
+			assertCommentIDs(t, got, tc.want...)
+		}
+	})
+
+	t.Run("roots page", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+
+		r1 := newComment(post.ID, nil, "r1")
+		mustCreateComment(t, comments, r1)
+		mustCreateComment(t, comments, newComment(post.ID, &r1.ID, "reply"))
+		r2 := newComment(post.ID, nil, "r2")
+		r3 := newComment(post.ID, nil, "r3")
+		mustCreateComment(t, comments, r2)
+		mustCreateComment(t, comments, r3)
+
+		cursor := func(c *domain.Comment) *pagination.Cursor {
+			return &pagination.Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
+		}
+		cases := []struct {
+			name   string
+			window pagination.Window
+			want   []string
+		}{
+			{"first", pagination.Window{Limit: 2}, []string{r1.ID, r2.ID}},
+			{"after", pagination.Window{After: cursor(r1), Limit: 5}, []string{r2.ID, r3.ID}},
+			{"after and before", pagination.Window{After: cursor(r1), Before: cursor(r3), Limit: 5}, []string{r2.ID}},
+			{"last", pagination.Window{Limit: 2, Backward: true}, []string{r2.ID, r3.ID}},
+			{"before", pagination.Window{Before: cursor(r3), Limit: 5, Backward: true}, []string{r1.ID, r2.ID}},
+			{"after last", pagination.Window{After: cursor(r3), Limit: 5}, nil},
+		}
+		for _, tc := range cases {
+			got, err := comments.GetRootsPage(ctx, post.ID, domain.CommentFilter{}, tc.window)
+			if err != nil {
+				t.Fatalf("GetRootsPage(%s): unexpected error: %v", tc.name, err)
+			}
+			assertCommentIDs(t, got, tc.want...)
+		}
+	})
+
+	t.Run("roots by time range", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+
+		r1 := newComment(post.ID, nil, "r1")
+		r2 := newComment(post.ID, nil, "r2")
+		mustCreateComment(t, comments, r1)
+		mustCreateComment(t, comments, r2)
+		mustCreateComment(t, comments, newComment(post.ID, &r1.ID, "reply"))
+		r3 := newComment(post.ID, nil, "r3")
+		mustCreateComment(t, comments, r3)
+
+		cases := []struct {
+			name   string
+			filter domain.CommentFilter
+			want   []string
+		}{
+			{"after", domain.CommentFilter{CreatedAfter: &r1.CreatedAt}, []string{r2.ID, r3.ID}},
+			{"before", domain.CommentFilter{CreatedBefore: &r3.CreatedAt}, []string{r1.ID, r2.ID}},
+			{"between", domain.CommentFilter{CreatedAfter: &r1.CreatedAt, CreatedBefore: &r3.CreatedAt}, []string{r2.ID}},
+		}
+		for _, tc := range cases {
+			got, err := comments.GetRootsByPostID(ctx, post.ID, tc.filter, domain.CommentOrderOld, 0, 0)
+			if err != nil {
+				t.Fatalf("GetRootsByPostID(%s): unexpected error: %v", tc.name, err)
+			}
+			assertCommentIDs(t, got, tc.want...)
+
+			got, err = comments.GetRootsPage(ctx, post.ID, tc.filter, pagination.Window{Limit: 5})
+			if err != nil {
+				t.Fatalf("GetRootsPage(%s): unexpected error: %v", tc.name, err)
+			}
+			assertCommentIDs(t, got, tc.want...)
+		}
+	})
+
+	t.Run("roots by post ids", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		a := newPost("a")
+		b := newPost("b")
+		c := newPost("c")
+		mustCreatePost(t, posts, a)
+		mustCreatePost(t, posts, b)
+		mustCreatePost(t, posts, c)
+
+		onA := newComment(a.ID, nil, "on a")
+		onC := newComment(c.ID, nil, "on c")
+		onB := newComment(b.ID, nil, "on b")
+		mustCreateComment(t, comments, onA)
+		mustCreateComment(t, comments, onC)
+		mustCreateComment(t, comments, onB)
+		mustCreateComment(t, comments, newComment(a.ID, &onA.ID, "reply"))
+
+		got, err := comments.GetRootsByPostIDs(ctx, []string{a.ID, b.ID}, domain.CommentOrderOld)
+		if err != nil {
+			t.Fatalf("GetRootsByPostIDs: unexpected error: %v", err)
+		}
+		assertCommentIDs(t, got, onA.ID, onB.ID)
+	})
+
+	t.Run("get by parent ids", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+
+		r1 := newComment(post.ID, nil, "r1")
+		r2 := newComment(post.ID, nil, "r2")
+		mustCreateComment(t, comments, r1)
+		mustCreateComment(t, comments, r2)
+		a := newComment(post.ID, &r1.ID, "a")
+		c := newComment(post.ID, &r2.ID, "c")
+		b := newComment(post.ID, &r1.ID, "b")
+		mustCreateComment(t, comments, a)
+		mustCreateComment(t, comments, c)
+		mustCreateComment(t, comments, b)
+		mustCreateComment(t, comments, newComment(post.ID, &a.ID, "nested"))
+
+		got, err := comments.GetByParentIDs(ctx, []string{r1.ID}, domain.CommentOrderOld)
+		if err != nil {
+			t.Fatalf("GetByParentIDs: unexpected error: %v", err)
+		}
+		assertCommentIDs(t, got, a.ID, b.ID)
+
+		got, err = comments.GetByParentIDs(ctx, []string{r1.ID, r2.ID}, domain.CommentOrderOld)
+		if err != nil {
+			t.Fatalf("GetByParentIDs: unexpected error: %v", err)
+		}
+		assertCommentIDs(t, got, a.ID, c.ID, b.ID)
+	})
+
+	t.Run("get with reply count", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+
+		root := newComment(post.ID, nil, "root")
+		mustCreateComment(t, comments, root)
+		reply := newComment(post.ID, &root.ID, "reply")
+		mustCreateComment(t, comments, reply)
+		mustCreateComment(t, comments, newComment(post.ID, &root.ID, "reply"))
+		mustCreateComment(t, comments, newComment(post.ID, &reply.ID, "nested"))
+
+		got, err := comments.Get(ctx, root.ID)
+		if err != nil {
+			t.Fatalf("Get: unexpected error: %v", err)
+		}
+		if got.ID != root.ID || got.Text != "root" {
+			t.Fatalf("expected %+v, got %+v", root, got)
+		}
+		if got.ReplyCount != 2 {
+			t.Fatalf("expected 2 direct replies, got %d", got.ReplyCount)
+		}
+
+		list, err := comments.GetByParentIDs(ctx, []string{root.ID}, domain.CommentOrderOld)
+		if err != nil {
+			t.Fatalf("GetByParentIDs: unexpected error: %v", err)
+		}
+		if list[0].ReplyCount != 1 || list[1].ReplyCount != 0 {
+			t.Fatalf("expected reply counts 1 and 0, got %d and %d", list[0].ReplyCount, list[1].ReplyCount)
+		}
+	})
+
+	t.Run("get not found", func(t *testing.T) {
+		_, comments := newRepos(t)
+
+		if _, err := comments.Get(ctx, uuid.NewString()); !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("Get: expected ErrNotFound for unknown comment, got %v", err)
+		}
+	})
+
+	t.Run("descendants", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+
+		r1 := newComment(post.ID, nil, "r1")
+		r2 := newComment(post.ID, nil, "r2")
+		mustCreateComment(t, comments, r1)
+		mustCreateComment(t, comments, r2)
+		a := newComment(post.ID, &r1.ID, "a")
+		b := newComment(post.ID, &r2.ID, "b")
+		mustCreateComment(t, comments, a)
+		mustCreateComment(t, comments, b)
+		aa := newComment(post.ID, &a.ID, "aa")
+		mustCreateComment(t, comments, aa)
+		ab := newComment(post.ID, &a.ID, "ab")
+		mustCreateComment(t, comments, ab)
+		aaa := newComment(post.ID, &aa.ID, "aaa")
+		mustCreateComment(t, comments, aaa)
+
+		cases := []struct {
+			name     string
+			parents  []string
+			maxDepth int
+			want     []string
+		}{
+			{"zero depth", []string{r1.ID}, 0, nil},
+			{"one level", []string{r1.ID, r2.ID}, 1, []string{a.ID, b.ID}},
+			{"two levels", []string{r1.ID}, 2, []string{a.ID, aa.ID, ab.ID}},
+			{"whole thread", []string{r1.ID, r2.ID}, 10, []string{a.ID, b.ID, aa.ID, ab.ID, aaa.ID}},
+			{"from reply", []string{a.ID}, 10, []string{aa.ID, ab.ID, aaa.ID}},
+			{"no parents", nil, 10, nil},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				got, err := comments.GetDescendants(ctx, tc.parents, tc.maxDepth, domain.CommentOrderOld)
+				if err != nil {
+					t.Fatalf("GetDescendants: unexpected error: %v", err)
+				}
+				assertCommentIDs(t, got, tc.want...)
+			})
+		}
+	})
+
+	t.Run("update text", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+		comment := newComment(post.ID, nil, "old")
+		mustCreateComment(t, comments, comment)
+
+		if err := comments.UpdateText(ctx, comment.ID, "new"); err != nil {
+			t.Fatalf("UpdateText: unexpected error: %v", err)
+		}
+
+		got, err := comments.Get(ctx, comment.ID)
+		if err != nil {
+			t.Fatalf("Get: unexpected error: %v", err)
+		}
+		if got.Text != "new" || !got.Edited {
+			t.Fatalf("expected edited text, got %+v", got)
+		}
+		if !got.UpdatedAt.After(got.CreatedAt) {
+			t.Fatalf("expected updated at %v to be after created at %v", got.UpdatedAt, got.CreatedAt)
+		}
+
+		if err := comments.UpdateText(ctx, uuid.NewString(), "new"); !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("UpdateText: expected ErrNotFound for unknown comment, got %v", err)
+		}
+	})
+
+	t.Run("depth", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+
+		root := newComment(post.ID, nil, "root")
+		mustCreateComment(t, comments, root)
+		reply := newComment(post.ID, &root.ID, "reply")
+		mustCreateComment(t, comments, reply)
+		nested := newComment(post.ID, &reply.ID, "nested")
+		mustCreateComment(t, comments, nested)
+
+		for want, c := range []*domain.Comment{root, reply, nested} {
+			if c.Depth != want+1 {
+				t.Fatalf("Create(%s): expected depth %d, got %d", c.Text, want+1, c.Depth)
+			}
+			got, err := comments.Get(ctx, c.ID)
+			if err != nil {
+				t.Fatalf("Get: unexpected error: %v", err)
+			}
+			if got.Depth != want+1 {
+				t.Fatalf("Get(%s): expected depth %d, got %d", c.Text, want+1, got.Depth)
+			}
+		}
+	})
+
+	t.Run("set locked", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+		c := newComment(post.ID, nil, "root")
+		mustCreateComment(t, comments, c)
+
+		if err := comments.SetLocked(ctx, c.ID, true); err != nil {
+			t.Fatalf("SetLocked: unexpected error: %v", err)
+		}
+		got, err := comments.Get(ctx, c.ID)
+		if err != nil {
+			t.Fatalf("Get: unexpected error: %v", err)
+		}
+		if !got.Locked {
+			t.Fatal("SetLocked: expected comment to be locked")
+		}
+
+		if err := comments.SetLocked(ctx, uuid.NewString(), true); !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("SetLocked: expected ErrNotFound for unknown comment, got %v", err)
+		}
+	})
+
+	t.Run("soft delete keeps replies", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+		root := newComment(post.ID, nil, "root")
+		mustCreateComment(t, comments, root)
+		reply := newComment(post.ID, &root.ID, "reply")
+		mustCreateComment(t, comments, reply)
+
+		if err := comments.SoftDelete(ctx, root.ID); err != nil {
+			t.Fatalf("SoftDelete: unexpected error: %v", err)
+		}
+
+		got, err := comments.Get(ctx, root.ID)
+		if err != nil {
+			t.Fatalf("Get: unexpected error: %v", err)
+		}
+		if got.DeletedAt == nil || got.Text != domain.CommentTombstone {
+			t.Fatalf("expected tombstone, got %+v", got)
+		}
+		replies, err := comments.GetByParentIDs(ctx, []string{root.ID}, domain.CommentOrderOld)
+		if err != nil {
+			t.Fatalf("GetByParentIDs: unexpected error: %v", err)
+		}
+		assertCommentIDs(t, replies, reply.ID)
+
+		if err := comments.SoftDelete(ctx, uuid.NewString()); !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("SoftDelete: expected ErrNotFound for unknown comment, got %v", err)
+		}
+	})
+
+	t.Run("delete leaf", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+		root := newComment(post.ID, nil, "root")
+		mustCreateComment(t, comments, root)
+		reply := newComment(post.ID, &root.ID, "reply")
+		mustCreateComment(t, comments, reply)
+
+		deleted, err := comments.DeleteLeaf(ctx, root.ID)
+		if err != nil {
+			t.Fatalf("DeleteLeaf: unexpected error: %v", err)
+		}
+		if deleted {
+			t.Fatal("DeleteLeaf: expected comment with replies to be kept")
+		}
+
+		deleted, err = comments.DeleteLeaf(ctx, reply.ID)
+		if err != nil {
+			t.Fatalf("DeleteLeaf: unexpected error: %v", err)
+		}
+		if !deleted {
+			t.Fatal("DeleteLeaf: expected leaf to be deleted")
+		}
+		if _, err := comments.Get(ctx, reply.ID); err == nil {
+			t.Fatal("Get: expected error for deleted comment")
+		}
+
+		got, err := comments.Get(ctx, root.ID)
+		if err != nil {
+			t.Fatalf("Get: unexpected error: %v", err)
+		}
+		if got.ReplyCount != 0 {
+			t.Fatalf("expected no replies left, got %d", got.ReplyCount)
+		}
+
+		if _, err := comments.DeleteLeaf(ctx, uuid.NewString()); !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("DeleteLeaf: expected ErrNotFound for unknown comment, got %v", err)
+		}
+	})
+
+	t.Run("votes", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+		a := newComment(post.ID, nil, "a")
+		b := newComment(post.ID, nil, "b")
+		mustCreateComment(t, comments, a)
+		mustCreateComment(t, comments, b)
+
+		mustVote(t, comments, a.ID, domain.VoteUp, "u1", "u2", "u3")
+		mustVote(t, comments, a.ID, domain.VoteDown, "u4")
+		mustVote(t, comments, b.ID, domain.VoteUp, "u1")
+		// Changing and taking back votes replaces the earlier vote.
+		mustVote(t, comments, a.ID, domain.VoteDown, "u3")
+		mustVote(t, comments, a.ID, domain.VoteNone, "u2")
+
+		got, err := comments.Get(ctx, a.ID)
+		if err != nil {
+			t.Fatalf("Get: unexpected error: %v", err)
+		}
+		if got.Upvotes != 1 || got.Downvotes != 2 {
+			t.Fatalf("expected 1 upvote and 2 downvotes, got %d and %d", got.Upvotes, got.Downvotes)
+		}
+
+		votes, err := comments.GetVotes(ctx, "u1", []string{a.ID, b.ID})
+		if err != nil {
+			t.Fatalf("GetVotes: unexpected error: %v", err)
+		}
+		if len(votes) != 2 || votes[a.ID] != domain.VoteUp || votes[b.ID] != domain.VoteUp {
+			t.Fatalf("expected upvotes on both comments, got %v", votes)
+		}
+		votes, err = comments.GetVotes(ctx, "u2", []string{a.ID, b.ID})
+		if err != nil {
+			t.Fatalf("GetVotes: unexpected error: %v", err)
+		}
+		if len(votes) != 0 {
+			t.Fatalf("expected no votes, got %v", votes)
+		}
+
+		if err := comments.SetVote(ctx, uuid.NewString(), "u1", domain.VoteUp); !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("SetVote: expected ErrNotFound for unknown comment, got %v", err)
+		}
+	})
+
+	t.Run("sort orders", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+		root := newComment(post.ID, nil, "root")
+		mustCreateComment(t, comments, root)
+
+		a := newComment(post.ID, &root.ID, "a")
+		b := newComment(post.ID, &root.ID, "b")
+		c := newComment(post.ID, &root.ID, "c")
+		d := newComment(post.ID, &root.ID, "d")
+		for _, comment := range []*domain.Comment{a, b, c, d} {
+			mustCreateComment(t, comments, comment)
+		}
+		mustVote(t, comments, a.ID, domain.VoteUp, "u1")
+		mustVote(t, comments, b.ID, domain.VoteUp, "u1", "u2", "u3")
+		mustVote(t, comments, b.ID, domain.VoteDown, "u4", "u5")
+		mustVote(t, comments, c.ID, domain.VoteUp, "u1", "u2", "u3", "u4", "u5")
+		mustVote(t, comments, d.ID, domain.VoteDown, "u1")
+
+		cases := []struct {
+			order domain.CommentOrder
+			want  []string
+		}{
+			{domain.CommentOrderOld, []string{a.ID, b.ID, c.ID, d.ID}},
+			{domain.CommentOrderNew, []string{d.ID, c.ID, b.ID, a.ID}},
+			// a and b have the same score, so the older one goes first.
+			{domain.CommentOrderTop, []string{c.ID, a.ID, b.ID, d.ID}},
+			{domain.CommentOrderBest, []string{c.ID, b.ID, a.ID, d.ID}},
+			{domain.CommentOrderControversial, []string{b.ID, a.ID, c.ID, d.ID}},
+		}
+		for _, tc := range cases {
+			t.Run(string(tc.order), func(t *testing.T) {
+				got, err := comments.GetByParentIDs(ctx, []string{root.ID}, tc.order)
+				if err != nil {
+					t.Fatalf("GetByParentIDs: unexpected error: %v", err)
+				}
+				assertCommentIDs(t, got, tc.want...)
+
+				got, err = comments.GetDescendants(ctx, []string{root.ID}, 1, tc.order)
+				if err != nil {
+					t.Fatalf("GetDescendants: unexpected error: %v", err)
+				}
+				assertCommentIDs(t, got, tc.want...)
+			})
+		}
+	})
+
+	t.Run("roots sort order", func(t *testing.T) {
+		posts, comments := newRepos(t)
+		post := newPost("title")
+		mustCreatePost(t, posts, post)
+		a := newComment(post.ID, nil, "a")
+		b := newComment(post.ID, nil, "b")
+		c := newComment(post.ID, nil, "c")
+		for _, comment := range []*domain.Comment{a, b, c} {
+			mustCreateComment(t, comments, comment)
+		}
+		mustVote(t, comments, b.ID, domain.VoteUp, "u1")
+
+		got, err := comments.GetRootsByPostID(ctx, post.ID, domain.CommentFilter{}, domain.CommentOrderTop, 2, 0)
+		if err != nil {
+			t.Fatalf("GetRootsByPostID: unexpected error: %v", err)
+		}
+		assertCommentIDs(t, got, b.ID, a.ID)
+
+		got, err = comments.GetRootsByPostIDs(ctx, []string{post.ID}, domain.CommentOrderNew)
+		if err != nil {
+			t.Fatalf("GetRootsByPostIDs: unexpected error: %v", err)
+		}
+		assertCommentIDs(t, got, c.ID, b.ID, a.ID)
+	})
+
+	t.Run("create for unknown post", func(t *testing.T) {
+		_, comments := newRepos(t)
+		if err := comments.Create(ctx, newComment(uuid.NewString(), nil, "orphan")); !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("Create: expected ErrNotFound for unknown post, got %v", err)
+		}
+	})
+}
+
+func newPost(title string) *domain.Post {
+	return &domain.Post{
+		ID:      uuid.NewString(),
+		Title:   title,
+		Content: "content of " + title,
+		Author:  "author",
+		Flag:    true,
+	}
+}
+
+func newComment(postID string, parentID *string, text string) *domain.Comment {
+	return &domain.Comment{
+		ID:       uuid.NewString(),
+		PostID:   postID,
+		ParentID: parentID,
+		Author:   "author",
+		Text:     text,
+	}
+}
+
+// Backends order by creation time, so give consecutive inserts distinct
+// timestamps even on clocks with coarse resolution.
+func tick() {
+	time.Sleep(2 * time.Millisecond)
+}
+
+func mustCreatePost(t *testing.T, posts repository.PostRepository, post *domain.Post) {
+	t.Helper()
+	if err := posts.Create(context.Background(), post); err != nil {
+		t.Fatalf("Create post: unexpected error: %v", err)
+	}
+	tick()
+}
+
+func mustCreateComment(t *testing.T, comments repository.CommentRepository, comment *domain.Comment) {
+	t.Helper()
+	if err := comments.Create(context.Background(), comment); err != nil {
+		t.Fatalf("Create comment: unexpected error: %v", err)
+	}
+	tick()
+}
+
+func mustVote(t *testing.T, comments repository.CommentRepository, commentID string, value domain.VoteValue, userIDs ...string) {
+	t.Helper()
+	for _, userID := range userIDs {
+		if err := comments.SetVote(context.Background(), commentID, userID, value); err != nil {
+			t.Fatalf("SetVote: unexpected error: %v", err)
+		}
+	}
+}
+
+func assertPostIDs(t *testing.T, got []*domain.Post, want ...string) {
+	t.Helper()
+	ids := make([]string, 0, len(got))
+	for _, p := range got {
+		ids = append(ids, p.ID)
+	}
+	assertIDs(t, ids, want)
+}
+
+func assertCommentIDs(t *testing.T, got []*domain.Comment, want ...string) {
+	t.Helper()
+	ids := make([]string, 0, len(got))
+	for _, c := range got {
+		ids = append(ids, c.ID)
+	}
+	assertIDs(t, ids, want)
+}
+
+func assertIDs(t *testing.T, got, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected ids %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected ids %v, got %v", want, got)
+		}
+	}
+}
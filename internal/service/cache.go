@@ -0,0 +1,345 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/cache"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/metrics"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// postsListKey holds the ids of the posts, newest first. The posts
+	// themselves are cached under postKey.
+	postsListKey = "posts:list"
+	// loadTimeout bounds loads shared by several requests, which are
+	// detached from the cancellation of the request that started them.
+	loadTimeout = 10 * time.Second
+)
+
+func postKey(id string) string { return "post:" + id }
+
+// commentsVersionKey holds a counter bumped on every change to the comments
+// of a post. It is part of the keys the comments of the post are cached
+// under, so bumping it leaves the old entries to expire unused.
+func commentsVersionKey(postID string) string { return "post:" + postID + ":comments:version" }
+
+func commentsKey(postID string, version int64, maxDepth int, order domain.CommentOrder) string {
+	return fmt.Sprintf("post:%s:comments:v%d:%d:%s", postID, version, maxDepth, order)
+}
+
+// CacheTTL controls how long cached values are used. A value is fresh for
+// Soft and then served stale, while it is refreshed in the background,
+// until Hard, when it is dropped from the cache. A Soft of 0 or not below
+// Hard disables serving stale values. A Hard of 0 keeps values until they
+// are invalidated, served stale after Soft if it is set.
+type CacheTTL struct {
+	Soft time.Duration
+	Hard time.Duration
+}
+
+// envelope is what is stored in the cache: the value along with the moment
+// it stops being fresh.
+type envelope[T any] struct {
+	FreshUntil time.Time `json:"freshUntil"`
+	Value      T         `json:"value"`
+}
+
+// fresh reports whether the value can be served without a refresh. A zero
+// FreshUntil never goes stale.
+func (e *envelope[T]) fresh() bool {
+	return e.FreshUntil.IsZero() || time.Now().Before(e.FreshUntil)
+}
+
+// readThrough loads values through a cache. Concurrent loads of the same
+// key are coalesced into one, so an invalidated hot key does not send
+// every request to the database at once.
+type readThrough struct {
+	cache cache.Cache
+	ttl   CacheTTL
+	group singleflight.Group
+	log   *slog.Logger
+
+	// Every invalidation gets the next number of seq, recorded against
+	// its keys in invalidated. A load only stores keys that were not
+	// invalidated since it began, so a value read before a change is
+	// never cached after it. Invalidations on other instances are not
+	// seen; the hard TTL bounds how long such a value can be served.
+	mu          sync.Mutex
+	seq         uint64
+	invalidated map[string]uint64
+}
+
+func newReadThrough(c cache.Cache, ttl CacheTTL, log *slog.Logger) *readThrough {
+	if ttl.Soft <= 0 || (ttl.Hard > 0 && ttl.Soft > ttl.Hard) {
+		ttl.Soft = ttl.Hard
+	}
+	return &readThrough{cache: c, ttl: ttl, log: log, invalidated: make(map[string]uint64)}
+}
+
+// getOrLoad returns the value cached under key, loading and caching it on
+// a miss. name labels the lookup in the cache metrics. A nil cache only
+// coalesces loads.
+func getOrLoad[T any](ctx context.Context, rt *readThrough, name, key string, load func(context.Context) (T, error)) (T, error) {
+	refresh := func() (any, error) {
+		return rt.load(ctx, key, func(ctx context.Context) (any, error) { return load(ctx) })
+	}
+
+	var e envelope[T]
+	if rt.get(ctx, name, key, &e) {
+		if e.fresh() {
+			metrics.CacheHit(name)
+			return e.Value, nil
+		}
+		metrics.CacheStale(name)
+		rt.group.DoChan(key, refresh)
+		return e.Value, nil
+	}
+	metrics.CacheMiss(name)
+
+	ch := rt.group.DoChan(key, refresh)
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			var zero T
+			return zero, res.Err
+		}
+		return res.Val.(T), nil
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// getMany returns the values cached under key(id) for every id, loading
+// the missing ones with a single call to load. Stale values are served and
+// refreshed together in the background. Concurrent loads of the same keys
+// are coalesced into one, like in getOrLoad. An empty key leaves the value
+// out of the cache. Ids load returns nothing for are left out of the
+// result.
+func getMany[T any](ctx context.Context, rt *readThrough, name string, ids []string, key func(string) string, load func(context.Context, []string) (map[string]T, error)) (map[string]T, error) {
+	values := make(map[string]T, len(ids))
+	var missing, stale []string
+	for _, id := range ids {
+		var e envelope[T]
+		if k := key(id); k == "" || !rt.get(ctx, name, k, &e) {
+			metrics.CacheMiss(name)
+			missing = append(missing, id)
+			continue
+		}
+		values[id] = e.Value
+		if e.fresh() {
+			metrics.CacheHit(name)
+			continue
+		}
+		metrics.CacheStale(name)
+		stale = append(stale, id)
+	}
+
+	if len(stale) > 0 {
+		rt.group.DoChan(flightKey(name, stale, key), func() (any, error) {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+			defer cancel()
+			return loadMany(ctx, rt, stale, key, load)
+		})
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	ch := rt.group.DoChan(flightKey(name, missing, key), func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+		return loadMany(ctx, rt, missing, key, load)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		for id, v := range res.Val.(map[string]T) {
+			values[id] = v
+		}
+		return values, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// flightKey identifies a load of ids in the singleflight group by the keys
+// the values are cached under, so a load of an outdated version of a key is
+// never joined. Ids without a key are identified by the id itself.
+func flightKey(name string, ids []string, key func(string) string) string {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		if keys[i] = key(id); keys[i] == "" {
+			keys[i] = id
+		}
+	}
+	return name + ":" + strings.Join(keys, ",")
+}
+
+func loadMany[T any](ctx context.Context, rt *readThrough, ids []string, key func(string) string, load func(context.Context, []string) (map[string]T, error)) (map[string]T, error) {
+	began := rt.begin()
+	loaded, err := recovered(func() (map[string]T, error) { return load(ctx, ids) })
+	if err != nil {
+		return nil, err
+	}
+	for id, v := range loaded {
+		if k := key(id); k != "" {
+			rt.store(ctx, k, v, began)
+		}
+	}
+	return loaded, nil
+}
+
+func (rt *readThrough) enabled() bool { return rt.cache != nil }
+
+// get decodes the envelope cached under key into e and reports whether it
+// was found.
+func (rt *readThrough) get(ctx context.Context, name, key string, e any) bool {
+	if rt.cache == nil {
+		return false
+	}
+	data, err := rt.cache.Get(ctx, key)
+	if err == nil {
+		if err = json.Unmarshal(data, e); err == nil {
+			return true
+		}
+	}
+	if !errors.Is(err, cache.ErrMiss) {
+		rt.log.Warn("failed get from cache", "cache", name, "key", key, "error", err)
+	}
+	return false
+}
+
+// load runs load detached from the cancellation of ctx, since other
+// requests may be waiting for it, and caches the result.
+func (rt *readThrough) load(ctx context.Context, key string, load func(context.Context) (any, error)) (any, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+	defer cancel()
+
+	began := rt.begin()
+	value, err := recovered(func() (any, error) { return load(ctx) })
+	if err != nil {
+		return nil, err
+	}
+	rt.store(ctx, key, value, began)
+	return value, nil
+}
+
+// recovered turns a panic in load into an error. Loads shared through the
+// singleflight group run in goroutines of their own, where a panic would
+// bypass the recovery of the resolvers and crash the server.
+func recovered[T any](load func() (T, error)) (value T, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			var zero T
+			value, err = zero, fmt.Errorf("panic in cache load: %v\n%s", p, debug.Stack())
+		}
+	}()
+	return load()
+}
+
+// begin marks the start of a load, to be passed on to store.
+func (rt *readThrough) begin() uint64 {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.seq
+}
+
+// current reports whether key was not invalidated since began.
+func (rt *readThrough) current(key string, began uint64) bool {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.invalidated[key] <= began
+}
+
+// store caches value, loaded since began, under key unless the key was
+// invalidated in the meantime. An invalidation racing the write is caught
+// by checking again afterwards and dropping what was written.
+func (rt *readThrough) store(ctx context.Context, key string, value any, began uint64) {
+	if rt.cache == nil || !rt.current(key, began) {
+		return
+	}
+	defer func() {
+		if !rt.current(key, began) {
+			_ = rt.cache.Delete(ctx, key)
+		}
+	}()
+	e := envelope[any]{Value: value}
+	if rt.ttl.Soft > 0 {
+		e.FreshUntil = time.Now().Add(rt.ttl.Soft)
+	}
+	data, err := json.Marshal(e)
+	if err == nil {
+		err = rt.cache.Set(ctx, key, data, rt.ttl.Hard)
+	}
+	if err != nil {
+		rt.log.Error("failed set to cache", "key", key, "error", err)
+	}
+}
+
+// versions returns the counters cached under keys, 0 for the ones there
+// are none of, read in a single round trip. Counters that could not be read
+// are left out, and keys derived from them must not be used.
+func (rt *readThrough) versions(ctx context.Context, keys ...string) map[string]int64 {
+	versions := make(map[string]int64, len(keys))
+	data, err := rt.cache.GetMany(ctx, keys...)
+	if err != nil {
+		rt.log.Warn("failed get versions from cache", "keys", keys, "error", err)
+		return versions
+	}
+	for i, key := range keys {
+		if data[i] == nil {
+			versions[key] = 0
+			continue
+		}
+		n, err := strconv.ParseInt(string(data[i]), 10, 64)
+		if err != nil {
+			rt.log.Warn("failed get version from cache", "key", key, "error", err)
+			continue
+		}
+		versions[key] = n
+	}
+	return versions
+}
+
+// bump increments the counter under key so the keys derived from it change.
+func (rt *readThrough) bump(ctx context.Context, key string) {
+	if rt.cache == nil {
+		return
+	}
+	if _, err := rt.cache.Incr(ctx, key); err != nil {
+		rt.log.Error("failed bump version in cache", "key", key, "error", err)
+	}
+}
+
+// invalidate drops keys from the cache. Loads of those keys already in
+// flight are no longer joined, and do not cache what they read, so later
+// requests see the change.
+func (rt *readThrough) invalidate(ctx context.Context, keys ...string) {
+	rt.mu.Lock()
+	rt.seq++
+	for _, key := range keys {
+		rt.invalidated[key] = rt.seq
+		rt.group.Forget(key)
+	}
+	rt.mu.Unlock()
+	if rt.cache == nil {
+		return
+	}
+	if err := rt.cache.Delete(ctx, keys...); err != nil {
+		rt.log.Warn("failed delete from cache", "keys", keys, "error", err)
+	}
+}
@@ -4,34 +4,69 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/auth"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/authz"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/cache"
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/events"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pagination"
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/repository"
-	"github.com/redis/go-redis/v9"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/tracing"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/validation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type CommentService struct {
-	repo     repository.CommentRepository
-	redis    *redis.Client
+	repo repository.CommentRepository
+	// cache holds the top-level comments of posts, with their replies,
+	// under keys versioned per post.
+	cache    *readThrough
+	events   *events.Bus
 	postRepo repository.PostRepository
+	policy   *authz.Policy
+	// maxDepth limits how deep replies may be nested, 0 means no limit.
+	maxDepth int
 	log      *slog.Logger
 }
 
-func NewCommentService(repo repository.CommentRepository, redis *redis.Client, postRepo repository.PostRepository, log *slog.Logger) *CommentService {
-	return &CommentService{repo: repo, redis: redis, postRepo: postRepo, log: log}
+// NewCommentService caches comments for cacheTTL.Hard. Versioned keys
+// never go stale, so cacheTTL.Soft is not used.
+// commentsTTL is how long comment trees are cached when no hard TTL is
+// set. Trees are never invalidated, only left behind by a version bump, so
+// they must expire on their own.
+const commentsTTL = 5 * time.Minute
+
+func NewCommentService(repo repository.CommentRepository, cache cache.Cache, cacheTTL CacheTTL, bus *events.Bus, postRepo repository.PostRepository, policy *authz.Policy, maxDepth int, log *slog.Logger) *CommentService {
+	ttl := CacheTTL{Hard: cacheTTL.Hard}
+	if ttl.Hard <= 0 {
+		ttl.Hard = commentsTTL
+	}
+	return &CommentService{
+		repo:     repo,
+		cache:    newReadThrough(cache, ttl, log),
+		events:   bus,
+		postRepo: postRepo,
+		policy:   policy,
+		maxDepth: maxDepth,
+		log:      log,
+	}
 }
 
-func (s *CommentService) Create(ctx context.Context, comment *domain.Comment) error {
+func (s *CommentService) Create(ctx context.Context, comment *domain.Comment) (err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.Create")
+	defer tracing.End(span, &err)
+
 	if comment == nil {
 		err := errors.New("comment is nil")
 		s.log.Error("failed create comment", "error", err)
 		return err
 	}
 
-	if comment.PostID == "" || comment.Author == "" || comment.Text == "" {
-		err := errors.New("postID, author and text are required")
-		s.log.Error("failed create comment", "error", err)
+	if err := validation.Comment(comment); err != nil {
+		s.log.Warn("failed create comment", "error", err)
 		return err
 	}
 
@@ -40,11 +75,38 @@ func (s *CommentService) Create(ctx context.Context, comment *domain.Comment) er
 		return err
 	}
 	if !post.Flag {
-		err = errors.New("comments are disabled for this post")
+		err = domain.ErrCommentsDisabled
 		s.log.Warn("flag is false", "error", err)
 		return err
 	}
 
+	if comment.ParentID != nil {
+		parent, err := s.repo.Get(ctx, *comment.ParentID)
+		if errors.Is(err, domain.ErrNotFound) {
+			err = validation.MissingParent()
+			s.log.Warn("failed create comment", "parentId", *comment.ParentID, "error", err)
+			return err
+		}
+		if err != nil {
+			s.log.Error("failed get parent comment repo", "error", err)
+			return err
+		}
+		if err := validation.Parent(comment, parent, s.maxDepth); err != nil {
+			s.log.Warn("failed create comment", "parentId", parent.ID, "error", err)
+			return err
+		}
+		switch {
+		case parent.DeletedAt != nil:
+			err = domain.Conflict("cannot reply to a deleted comment")
+		case parent.Locked:
+			err = domain.Conflict("comment is locked")
+		}
+		if err != nil {
+			s.log.Warn("failed create comment", "parentId", parent.ID, "error", err)
+			return err
+		}
+	}
+
 	if comment.ID == "" {
 		comment.ID = uuid.NewString()
 	}
@@ -53,24 +115,507 @@ func (s *CommentService) Create(ctx context.Context, comment *domain.Comment) er
 		s.log.Error("failed create comment repo", "error", err)
 		return err
 	}
+	s.changed(ctx, comment.PostID)
+	s.events.Publish(ctx, events.Event{Type: events.CommentAdded, PostID: comment.PostID, Comment: comment})
+
+	return nil
+}
 
-	if s.redis != nil {
-		if err := s.redis.Del(ctx, "post:"+comment.PostID).Err(); err != nil {
-			s.log.Warn("failed del from redis", "error", err)
+// Edit replaces the text of one of the caller's comments.
+func (s *CommentService) Edit(ctx context.Context, id, text string) (_ *domain.Comment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.Edit", trace.WithAttributes(tracing.ID("comment", id)))
+	defer tracing.End(span, &err)
+
+	if id == "" {
+		err := domain.Validation("id is required")
+		s.log.Error("failed edit comment", "error", err)
+		return nil, err
+	}
+	text, err = validation.CommentText(text)
+	if err != nil {
+		s.log.Warn("failed edit comment", "commentId", id, "error", err)
+		return nil, err
+	}
+
+	comment, err := s.authorize(ctx, authz.ActionEditComment, id)
+	if err != nil {
+		return nil, err
+	}
+	if comment.DeletedAt != nil {
+		err := domain.Conflict("comment is deleted")
+		s.log.Warn("failed edit comment", "commentId", id, "error", err)
+		return nil, err
+	}
+
+	if err := s.repo.UpdateText(ctx, id, text); err != nil {
+		s.log.Error("failed edit comment repo", "error", err)
+		return nil, err
+	}
+	s.changed(ctx, comment.PostID)
+
+	return s.edited(ctx, events.CommentEdited, id)
+}
+
+// Delete removes one of the caller's comments. Comments with replies are
+// replaced by a tombstone so the replies stay in the tree.
+func (s *CommentService) Delete(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.Delete", trace.WithAttributes(tracing.ID("comment", id)))
+	defer tracing.End(span, &err)
+
+	if id == "" {
+		err := domain.Validation("id is required")
+		s.log.Error("failed delete comment", "error", err)
+		return err
+	}
+
+	comment, err := s.authorize(ctx, authz.ActionDeleteComment, id)
+	if err != nil {
+		return err
+	}
+	if comment.DeletedAt != nil {
+		err := domain.Conflict("comment is already deleted")
+		s.log.Warn("failed delete comment", "commentId", id, "error", err)
+		return err
+	}
+
+	deleted, err := s.repo.DeleteLeaf(ctx, id)
+	if err != nil {
+		s.log.Error("failed delete comment repo", "error", err)
+		return err
+	}
+	event := events.Event{Type: events.CommentDeleted, PostID: comment.PostID, CommentID: id}
+	if !deleted {
+		if err := s.repo.SoftDelete(ctx, id); err != nil {
+			s.log.Error("failed soft delete comment repo", "error", err)
+			return err
 		}
-		if err := s.redis.Del(ctx, "posts:list").Err(); err != nil {
-			s.log.Warn("failed del from redis", "error", err)
+		if event.Comment, err = s.repo.Get(ctx, id); err != nil {
+			s.log.Error("failed get comment repo", "error", err)
 		}
 	}
-
+	s.changed(ctx, comment.PostID)
+	s.events.Publish(ctx, event)
 	return nil
 }
 
-func (s *CommentService) GetByPostID(ctx context.Context, postID string) ([]*domain.Comment, error) {
+// Vote records the caller's vote on a comment; VoteNone takes it back.
+func (s *CommentService) Vote(ctx context.Context, id string, value domain.VoteValue) (_ *domain.Comment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.Vote", trace.WithAttributes(tracing.ID("comment", id)))
+	defer tracing.End(span, &err)
+
+	if id == "" {
+		err := domain.Validation("id is required")
+		s.log.Error("failed vote comment", "error", err)
+		return nil, err
+	}
+	switch value {
+	case domain.VoteUp, domain.VoteDown, domain.VoteNone:
+	default:
+		err := domain.Validation("unknown vote value")
+		s.log.Error("failed vote comment", "error", err, "value", value)
+		return nil, err
+	}
+
+	comment, err := s.authorize(ctx, authz.ActionVoteComment, id)
+	if err != nil {
+		return nil, err
+	}
+	if comment.DeletedAt != nil {
+		err := domain.Conflict("comment is deleted")
+		s.log.Warn("failed vote comment", "commentId", id, "error", err)
+		return nil, err
+	}
+
+	principal, err := auth.RequirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetVote(ctx, id, principal.Subject, value); err != nil {
+		s.log.Error("failed vote comment repo", "error", err)
+		return nil, err
+	}
+	s.changed(ctx, comment.PostID)
+
+	return s.edited(ctx, events.CommentVoted, id)
+}
+
+// Lock stops or allows new replies to a comment. Existing replies are kept.
+func (s *CommentService) Lock(ctx context.Context, id string, locked bool) (_ *domain.Comment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.Lock", trace.WithAttributes(tracing.ID("comment", id)))
+	defer tracing.End(span, &err)
+
+	if id == "" {
+		err := domain.Validation("id is required")
+		s.log.Error("failed lock comment", "error", err)
+		return nil, err
+	}
+
+	comment, err := s.authorize(ctx, authz.ActionLockComment, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetLocked(ctx, id, locked); err != nil {
+		s.log.Error("failed lock comment repo", "error", err)
+		return nil, err
+	}
+	s.changed(ctx, comment.PostID)
+
+	return s.edited(ctx, events.CommentLocked, id)
+}
+
+// GetMyVotes returns the caller's votes on the given comments. Anonymous
+// callers have not voted on anything.
+func (s *CommentService) GetMyVotes(ctx context.Context, commentIDs []string) (_ map[string]domain.VoteValue, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.GetMyVotes")
+	defer tracing.End(span, &err)
+
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return map[string]domain.VoteValue{}, nil
+	}
+
+	votes, err := s.repo.GetVotes(ctx, principal.Subject, commentIDs)
+	if err != nil {
+		s.log.Error("failed get votes repo", "error", err)
+		return nil, err
+	}
+	return votes, nil
+}
+
+func (s *CommentService) commentOrder(order domain.CommentOrder) (domain.CommentOrder, error) {
+	switch order {
+	case "":
+		return domain.CommentOrderOld, nil
+	case domain.CommentOrderNew, domain.CommentOrderOld, domain.CommentOrderTop,
+		domain.CommentOrderBest, domain.CommentOrderControversial:
+		return order, nil
+	default:
+		err := domain.Validation("unknown comments order")
+		s.log.Error("failed get comments", "error", err, "order", order)
+		return "", err
+	}
+}
+
+// edited reloads a changed comment and publishes it as an event of type t.
+func (s *CommentService) edited(ctx context.Context, t events.Type, id string) (*domain.Comment, error) {
+	comment, err := s.repo.Get(ctx, id)
+	if err != nil {
+		s.log.Error("failed get comment repo", "error", err)
+		return nil, err
+	}
+	s.events.Publish(ctx, events.Event{Type: t, PostID: comment.PostID, Comment: comment})
+	return comment, nil
+}
+
+// changed moves the comments of a post to a new cache version, leaving the
+// comments of other posts cached.
+func (s *CommentService) changed(ctx context.Context, postID string) {
+	s.cache.bump(ctx, commentsVersionKey(postID))
+}
+
+// byPost serves the comments of every post from the cache, calling load
+// for the posts that are missing. Posts without comments get an empty
+// slice.
+func (s *CommentService) byPost(ctx context.Context, postIDs []string, maxDepth int, order domain.CommentOrder, load func(context.Context, []string) ([]*domain.Comment, error)) (map[string][]*domain.Comment, error) {
+	var versions map[string]int64
+	if s.cache.enabled() {
+		keys := make([]string, len(postIDs))
+		for i, id := range postIDs {
+			keys[i] = commentsVersionKey(id)
+		}
+		versions = s.cache.versions(ctx, keys...)
+	}
+	key := func(postID string) string {
+		v, ok := versions[commentsVersionKey(postID)]
+		if !ok {
+			return ""
+		}
+		return commentsKey(postID, v, maxDepth, order)
+	}
+
+	return getMany(ctx, s.cache, "comments", postIDs, key, func(ctx context.Context, ids []string) (map[string][]*domain.Comment, error) {
+		comments, err := load(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		byPost := make(map[string][]*domain.Comment, len(ids))
+		for _, id := range ids {
+			byPost[id] = []*domain.Comment{}
+		}
+		for _, c := range comments {
+			byPost[c.PostID] = append(byPost[c.PostID], c)
+		}
+		return byPost, nil
+	})
+}
+
+func (s *CommentService) authorize(ctx context.Context, action authz.Action, id string) (*domain.Comment, error) {
+	comment, err := s.repo.Get(ctx, id)
+	if err != nil {
+		s.log.Error("failed get comment repo", "error", err)
+		return nil, err
+	}
+	if err := s.policy.Authorize(ctx, action, authz.Resource{OwnerID: comment.Author}); err != nil {
+		s.log.Warn("comment action denied", "action", action, "commentId", id, "error", err)
+		return nil, err
+	}
+	return comment, nil
+}
+
+func (s *CommentService) GetByPostID(ctx context.Context, postID string) (_ []*domain.Comment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.GetByPostID", trace.WithAttributes(tracing.ID("post", postID)))
+	defer tracing.End(span, &err)
+
 	if postID == "" {
-		err := errors.New("postID is required")
+		err := domain.Validation("postID is required")
 		s.log.Error("failed get post", "error", err)
 		return nil, err
 	}
 	return s.repo.GetByPostID(ctx, postID)
 }
+
+func (s *CommentService) GetRootsByPostID(ctx context.Context, postID string, filter domain.CommentFilter, order domain.CommentOrder, limit, offset *int) (_ []*domain.Comment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.GetRootsByPostID", trace.WithAttributes(tracing.ID("post", postID)))
+	defer tracing.End(span, &err)
+
+	if postID == "" {
+		err := domain.Validation("postID is required")
+		s.log.Error("failed get comments page", "error", err)
+		return nil, err
+	}
+	order, err = s.commentOrder(order)
+	if err != nil {
+		return nil, err
+	}
+
+	l, o := 0, 0
+	if limit != nil {
+		if *limit < 0 {
+			return nil, domain.Validation("limit must not be negative")
+		}
+		if *limit == 0 {
+			return []*domain.Comment{}, nil
+		}
+		l = *limit
+	}
+	if offset != nil {
+		if *offset < 0 {
+			return nil, domain.Validation("offset must not be negative")
+		}
+		o = *offset
+	}
+
+	roots, err := s.repo.GetRootsByPostID(ctx, postID, filter, order, l, o)
+	if err != nil {
+		s.log.Error("failed get comments page repo", "error", err)
+		return nil, err
+	}
+
+	return roots, nil
+}
+
+func (s *CommentService) GetRootsPage(ctx context.Context, postID string, filter domain.CommentFilter, args pagination.Args) (_ pagination.Page[*domain.Comment], err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.GetRootsPage", trace.WithAttributes(tracing.ID("post", postID)))
+	defer tracing.End(span, &err)
+
+	if postID == "" {
+		err := domain.Validation("postID is required")
+		s.log.Error("failed get comments page", "error", err)
+		return pagination.Page[*domain.Comment]{}, err
+	}
+
+	window, err := args.Window()
+	if err != nil {
+		return pagination.Page[*domain.Comment]{}, err
+	}
+
+	roots, err := s.repo.GetRootsPage(ctx, postID, filter, window)
+	if err != nil {
+		s.log.Error("failed get comments page repo", "error", err)
+		return pagination.Page[*domain.Comment]{}, err
+	}
+
+	return pagination.NewPage(roots, window), nil
+}
+
+func (s *CommentService) GetRootsByPostIDs(ctx context.Context, postIDs []string, order domain.CommentOrder) (_ map[string][]*domain.Comment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.GetRootsByPostIDs")
+	defer tracing.End(span, &err)
+
+	order, err = s.commentOrder(order)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.byPost(ctx, postIDs, 0, order, func(ctx context.Context, ids []string) ([]*domain.Comment, error) {
+		comments, err := s.repo.GetRootsByPostIDs(ctx, ids, order)
+		if err != nil {
+			s.log.Error("failed get comments of posts repo", "error", err)
+			return nil, err
+		}
+		return comments, nil
+	})
+}
+
+func (s *CommentService) GetChildrenByParentIDs(ctx context.Context, parentIDs []string, order domain.CommentOrder) (_ map[string][]*domain.Comment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.GetChildrenByParentIDs")
+	defer tracing.End(span, &err)
+
+	order, err = s.commentOrder(order)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := s.repo.GetByParentIDs(ctx, parentIDs, order)
+	if err != nil {
+		s.log.Error("failed get replies repo", "error", err)
+		return nil, err
+	}
+
+	byParent := make(map[string][]*domain.Comment, len(parentIDs))
+	for _, c := range comments {
+		byParent[*c.ParentID] = append(byParent[*c.ParentID], c)
+	}
+	return byParent, nil
+}
+
+// GetRootTreesByPostIDs returns the top-level comments of every post with
+// their replies loaded down to maxDepth levels, roots being the first.
+func (s *CommentService) GetRootTreesByPostIDs(ctx context.Context, postIDs []string, maxDepth int, order domain.CommentOrder) (_ map[string][]*domain.Comment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.GetRootTreesByPostIDs")
+	defer tracing.End(span, &err)
+
+	if maxDepth < 1 {
+		return nil, domain.Validation("maxDepth must be positive")
+	}
+	order, err = s.commentOrder(order)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.byPost(ctx, postIDs, maxDepth, order, func(ctx context.Context, ids []string) ([]*domain.Comment, error) {
+		roots, err := s.repo.GetRootsByPostIDs(ctx, ids, order)
+		if err != nil {
+			s.log.Error("failed get comments of posts repo", "error", err)
+			return nil, err
+		}
+		if err := s.ExpandReplies(ctx, roots, maxDepth, order); err != nil {
+			return nil, err
+		}
+		return roots, nil
+	})
+}
+
+// GetRepliesByParentIDs returns the replies of every parent with their own
+// replies loaded down to maxDepth levels, direct replies being the first.
+func (s *CommentService) GetRepliesByParentIDs(ctx context.Context, parentIDs []string, maxDepth int, order domain.CommentOrder) (_ map[string][]*domain.Comment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.GetRepliesByParentIDs")
+	defer tracing.End(span, &err)
+
+	if maxDepth < 1 {
+		return nil, domain.Validation("maxDepth must be positive")
+	}
+	order, err = s.commentOrder(order)
+	if err != nil {
+		return nil, err
+	}
+
+	descendants, err := s.repo.GetDescendants(ctx, parentIDs, maxDepth, order)
+	if err != nil {
+		s.log.Error("failed get replies repo", "error", err)
+		return nil, err
+	}
+
+	parents := make([]*domain.Comment, 0, len(parentIDs))
+	for _, id := range parentIDs {
+		parents = append(parents, &domain.Comment{ID: id})
+	}
+	attachReplies(parents, descendants, 0, maxDepth)
+
+	byParent := make(map[string][]*domain.Comment, len(parentIDs))
+	for _, p := range parents {
+		byParent[p.ID] = p.Children
+	}
+	return byParent, nil
+}
+
+// GetThread returns a comment with its replies loaded down to maxDepth
+// levels, the comment itself being the first.
+func (s *CommentService) GetThread(ctx context.Context, id string, maxDepth int, order domain.CommentOrder) (_ *domain.Comment, err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.GetThread", trace.WithAttributes(tracing.ID("comment", id)))
+	defer tracing.End(span, &err)
+
+	if id == "" {
+		err := domain.Validation("id is required")
+		s.log.Error("failed get thread", "error", err)
+		return nil, err
+	}
+
+	comment, err := s.repo.Get(ctx, id)
+	if err != nil {
+		s.log.Error("failed get thread repo", "error", err)
+		return nil, err
+	}
+	if err := s.ExpandReplies(ctx, []*domain.Comment{comment}, maxDepth, order); err != nil {
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+// ExpandReplies loads the replies of comments down to maxDepth levels,
+// counting the comments themselves as the first level.
+func (s *CommentService) ExpandReplies(ctx context.Context, comments []*domain.Comment, maxDepth int, order domain.CommentOrder) (err error) {
+	ctx, span := tracer.Start(ctx, "CommentService.ExpandReplies")
+	defer tracing.End(span, &err)
+
+	if maxDepth < 1 {
+		return domain.Validation("maxDepth must be positive")
+	}
+	order, err = s.commentOrder(order)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(comments))
+	for _, c := range comments {
+		ids = append(ids, c.ID)
+	}
+
+	descendants, err := s.repo.GetDescendants(ctx, ids, maxDepth-1, order)
+	if err != nil {
+		s.log.Error("failed get replies repo", "error", err)
+		return err
+	}
+
+	attachReplies(comments, descendants, 1, maxDepth)
+	return nil
+}
+
+// attachReplies hangs descendants, ordered level by level, under the
+// comments at level top. Comments on the last level get no children and
+// are marked if they have replies that were cut off.
+func attachReplies(top, descendants []*domain.Comment, level, maxDepth int) {
+	levels := make(map[string]int, len(top)+len(descendants))
+	byID := make(map[string]*domain.Comment, len(top)+len(descendants))
+	for _, c := range top {
+		levels[c.ID], byID[c.ID] = level, c
+		c.Children = []*domain.Comment{}
+	}
+	for _, c := range descendants {
+		parent, ok := byID[*c.ParentID]
+		if !ok {
+			continue
+		}
+		levels[c.ID], byID[c.ID] = levels[parent.ID]+1, c
+		c.Children = []*domain.Comment{}
+		parent.Children = append(parent.Children, c)
+	}
+
+	for id, c := range byID {
+		if levels[id] == maxDepth {
+			c.HasMoreReplies = c.ReplyCount > 0
+		}
+	}
+}
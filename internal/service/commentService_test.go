@@ -5,9 +5,17 @@ import (
 	"errors"
 	"io"
 	"log/slog"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/auth"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/authz"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/cache"
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/events"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pagination"
 )
 
 func TestCommentService_Create(t *testing.T) {
@@ -26,7 +34,7 @@ func TestCommentService_Create(t *testing.T) {
 			},
 		}
 
-		s := NewCommentService(mockCommentRepo, nil, mockPostRepo, logger)
+		s := NewCommentService(mockCommentRepo, nil, testCacheTTL, nil, mockPostRepo, authz.DefaultPolicy(), 0, logger)
 
 		comment := &domain.Comment{
 			PostID: "post-1",
@@ -45,7 +53,7 @@ func TestCommentService_Create(t *testing.T) {
 	})
 
 	t.Run("nil comment", func(t *testing.T) {
-		s := NewCommentService(nil, nil, nil, logger)
+		s := NewCommentService(nil, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, logger)
 		err := s.Create(context.Background(), nil)
 		if err == nil {
 			t.Fatal("expected error for nil comment")
@@ -53,10 +61,10 @@ func TestCommentService_Create(t *testing.T) {
 	})
 
 	t.Run("missing fields", func(t *testing.T) {
-		s := NewCommentService(nil, nil, nil, logger)
+		s := NewCommentService(nil, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, logger)
 		err := s.Create(context.Background(), &domain.Comment{})
-		if err == nil {
-			t.Fatal("expected error for missing fields")
+		if !errors.Is(err, domain.ErrValidation) {
+			t.Fatalf("expected ErrValidation, got %v", err)
 		}
 	})
 
@@ -73,7 +81,7 @@ func TestCommentService_Create(t *testing.T) {
 			},
 		}
 
-		s := NewCommentService(mockCommentRepo, nil, mockPostRepo, logger)
+		s := NewCommentService(mockCommentRepo, nil, testCacheTTL, nil, mockPostRepo, authz.DefaultPolicy(), 0, logger)
 
 		comment := &domain.Comment{
 			PostID: "post-1",
@@ -82,8 +90,8 @@ func TestCommentService_Create(t *testing.T) {
 		}
 
 		err := s.Create(context.Background(), comment)
-		if err == nil {
-			t.Fatal("expected error when comments are forbidden")
+		if !errors.Is(err, domain.ErrCommentsDisabled) {
+			t.Fatalf("expected ErrCommentsDisabled, got %v", err)
 		}
 	})
 
@@ -100,7 +108,7 @@ func TestCommentService_Create(t *testing.T) {
 			},
 		}
 
-		s := NewCommentService(mockCommentRepo, nil, mockPostRepo, logger)
+		s := NewCommentService(mockCommentRepo, nil, testCacheTTL, nil, mockPostRepo, authz.DefaultPolicy(), 0, logger)
 
 		comment := &domain.Comment{
 			PostID: "post-1",
@@ -113,6 +121,87 @@ func TestCommentService_Create(t *testing.T) {
 			t.Fatal("expected error from post repo")
 		}
 	})
+
+	t.Run("parent on another post", func(t *testing.T) {
+		created := false
+		mockCommentRepo := &mockCommentRepo{
+			createFunc: func(ctx context.Context, comment *domain.Comment) error {
+				created = true
+				return nil
+			},
+			getFunc: func(ctx context.Context, id string) (*domain.Comment, error) {
+				return &domain.Comment{ID: id, PostID: "post-2"}, nil
+			},
+		}
+		mockPostRepo := &mockPostRepo{
+			getFunc: func(ctx context.Context, postID string) (*domain.Post, error) {
+				return &domain.Post{ID: postID, Flag: true}, nil
+			},
+		}
+
+		s := NewCommentService(mockCommentRepo, nil, testCacheTTL, nil, mockPostRepo, authz.DefaultPolicy(), 0, logger)
+
+		parentID := "c1"
+		err := s.Create(context.Background(), &domain.Comment{
+			PostID:   "post-1",
+			ParentID: &parentID,
+			Text:     "hello",
+			Author:   "user",
+		})
+		if !errors.Is(err, domain.ErrValidation) {
+			t.Fatalf("expected ErrValidation, got %v", err)
+		}
+		if created {
+			t.Fatal("expected the comment not to be created")
+		}
+	})
+
+	deletedAt := time.Now()
+	replyCases := []struct {
+		name    string
+		parent  *domain.Comment
+		err     error
+		wantErr error
+	}{
+		{"reply", &domain.Comment{PostID: "post-1", Depth: 2}, nil, nil},
+		{"missing parent", nil, domain.NotFound("comment"), domain.ErrValidation},
+		{"deleted parent", &domain.Comment{PostID: "post-1", Depth: 1, DeletedAt: &deletedAt}, nil, domain.ErrConflict},
+		{"locked parent", &domain.Comment{PostID: "post-1", Depth: 1, Locked: true}, nil, domain.ErrConflict},
+		{"too deep", &domain.Comment{PostID: "post-1", Depth: 3}, nil, domain.ErrValidation},
+	}
+	for _, tc := range replyCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCommentRepo := &mockCommentRepo{
+				createFunc: func(ctx context.Context, comment *domain.Comment) error {
+					return nil
+				},
+				getFunc: func(ctx context.Context, id string) (*domain.Comment, error) {
+					return tc.parent, tc.err
+				},
+			}
+			mockPostRepo := &mockPostRepo{
+				getFunc: func(ctx context.Context, postID string) (*domain.Post, error) {
+					return &domain.Post{ID: postID, Flag: true}, nil
+				},
+			}
+
+			s := NewCommentService(mockCommentRepo, nil, testCacheTTL, nil, mockPostRepo, authz.DefaultPolicy(), 3, logger)
+
+			parentID := "c1"
+			err := s.Create(context.Background(), &domain.Comment{
+				PostID:   "post-1",
+				ParentID: &parentID,
+				Text:     "hello",
+				Author:   "user",
+			})
+			if tc.wantErr == nil && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
 }
 
 func TestCommentService_GetByPostID(t *testing.T) {
@@ -128,7 +217,7 @@ func TestCommentService_GetByPostID(t *testing.T) {
 			},
 		}
 
-		s := NewCommentService(mockRepo, nil, nil, log)
+		s := NewCommentService(mockRepo, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
 
 		comments, err := s.GetByPostID(context.Background(), "post-1")
 		if err != nil {
@@ -141,7 +230,7 @@ func TestCommentService_GetByPostID(t *testing.T) {
 	})
 
 	t.Run("empty postID", func(t *testing.T) {
-		s := NewCommentService(nil, nil, nil, log)
+		s := NewCommentService(nil, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
 
 		_, err := s.GetByPostID(context.Background(), "")
 		if err == nil {
@@ -156,7 +245,7 @@ func TestCommentService_GetByPostID(t *testing.T) {
 			},
 		}
 
-		s := NewCommentService(mockRepo, nil, nil, log)
+		s := NewCommentService(mockRepo, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
 
 		_, err := s.GetByPostID(context.Background(), "post-1")
 		if err == nil {
@@ -164,3 +253,631 @@ func TestCommentService_GetByPostID(t *testing.T) {
 		}
 	})
 }
+
+func TestCommentService_GetRootsByPostID(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := &mockCommentRepo{
+			getRootsByPostIDFunc: func(ctx context.Context, postID string, filter domain.CommentFilter, order domain.CommentOrder, limit, offset int) ([]*domain.Comment, error) {
+				if limit != 1 || offset != 2 {
+					t.Fatalf("expected limit 1 and offset 2, got %d and %d", limit, offset)
+				}
+				if order != domain.CommentOrderOld {
+					t.Fatalf("expected default order %s, got %s", domain.CommentOrderOld, order)
+				}
+				return []*domain.Comment{{ID: "c1", PostID: postID}}, nil
+			},
+		}
+
+		s := NewCommentService(mockRepo, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
+
+		limit, offset := 1, 2
+		comments, err := s.GetRootsByPostID(ctx, "post-1", domain.CommentFilter{}, "", &limit, &offset)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(comments) != 1 {
+			t.Fatalf("expected 1 comment, got %d", len(comments))
+		}
+	})
+
+	t.Run("negative limit", func(t *testing.T) {
+		s := NewCommentService(&mockCommentRepo{}, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
+
+		limit := -1
+		_, err := s.GetRootsByPostID(ctx, "post-1", domain.CommentFilter{}, "", &limit, nil)
+		if err == nil {
+			t.Fatal("expected error for negative limit")
+		}
+	})
+
+	t.Run("unknown order", func(t *testing.T) {
+		s := NewCommentService(&mockCommentRepo{}, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
+
+		if _, err := s.GetRootsByPostID(ctx, "post-1", domain.CommentFilter{}, "RANDOM", nil, nil); err == nil {
+			t.Fatal("expected error for unknown order")
+		}
+	})
+
+	t.Run("empty postID", func(t *testing.T) {
+		s := NewCommentService(nil, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
+
+		_, err := s.GetRootsByPostID(ctx, "", domain.CommentFilter{}, "", nil, nil)
+		if err == nil {
+			t.Fatal("expected error for empty postID")
+		}
+	})
+}
+
+func TestCommentService_GetRootsPage(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	mockRepo := &mockCommentRepo{
+		getRootsPageFunc: func(ctx context.Context, postID string, filter domain.CommentFilter, window pagination.Window) ([]*domain.Comment, error) {
+			if window.Limit != 3 {
+				t.Fatalf("expected limit 3, got %d", window.Limit)
+			}
+			return []*domain.Comment{{ID: "c1"}, {ID: "c2"}, {ID: "c3"}}, nil
+		},
+	}
+
+	s := NewCommentService(mockRepo, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
+
+	t.Run("forward", func(t *testing.T) {
+		first := 2
+		page, err := s.GetRootsPage(ctx, "post-1", domain.CommentFilter{}, pagination.Args{First: &first})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(page.Items) != 2 || page.Items[1].ID != "c2" {
+			t.Fatalf("expected c1 and c2, got %+v", page.Items)
+		}
+		if !page.HasNextPage || page.HasPreviousPage {
+			t.Fatalf("unexpected page info: next=%v prev=%v", page.HasNextPage, page.HasPreviousPage)
+		}
+	})
+
+	t.Run("backward", func(t *testing.T) {
+		last := 2
+		page, err := s.GetRootsPage(ctx, "post-1", domain.CommentFilter{}, pagination.Args{Last: &last})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(page.Items) != 2 || page.Items[0].ID != "c2" {
+			t.Fatalf("expected c2 and c3, got %+v", page.Items)
+		}
+		if page.HasNextPage || !page.HasPreviousPage {
+			t.Fatalf("unexpected page info: next=%v prev=%v", page.HasNextPage, page.HasPreviousPage)
+		}
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		after := "not a cursor"
+		_, err := s.GetRootsPage(ctx, "post-1", domain.CommentFilter{}, pagination.Args{After: &after})
+		if !errors.Is(err, domain.ErrValidation) {
+			t.Fatalf("expected ErrValidation, got %v", err)
+		}
+	})
+}
+
+func TestCommentService_GetChildrenByParentIDs(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	p1, p2 := "c1", "c2"
+
+	mockRepo := &mockCommentRepo{
+		getByParentIDsFunc: func(ctx context.Context, parentIDs []string, order domain.CommentOrder) ([]*domain.Comment, error) {
+			return []*domain.Comment{
+				{ID: "r1", ParentID: &p1},
+				{ID: "r2", ParentID: &p2},
+				{ID: "r3", ParentID: &p1},
+			}, nil
+		},
+	}
+
+	s := NewCommentService(mockRepo, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
+
+	got, err := s.GetChildrenByParentIDs(context.Background(), []string{p1, p2, "c3"}, "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(got[p1]) != 2 || got[p1][0].ID != "r1" || got[p1][1].ID != "r3" {
+		t.Fatalf("expected r1 and r3 for %s, got %+v", p1, got[p1])
+	}
+	if len(got[p2]) != 1 || len(got["c3"]) != 0 {
+		t.Fatalf("unexpected grouping: %+v", got)
+	}
+}
+
+func TestCommentService_GetRootTreesCachedPerPost(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	alice := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice"})
+
+	var loaded [][]string
+	mockRepo := &mockCommentRepo{
+		getRootsByPostIDsFunc: func(ctx context.Context, postIDs []string, order domain.CommentOrder) ([]*domain.Comment, error) {
+			loaded = append(loaded, postIDs)
+			var roots []*domain.Comment
+			for _, id := range postIDs {
+				if id != "empty" {
+					roots = append(roots, &domain.Comment{ID: "c-" + id, PostID: id})
+				}
+			}
+			return roots, nil
+		},
+		getFunc: func(ctx context.Context, id string) (*domain.Comment, error) {
+			return &domain.Comment{ID: id, PostID: "p1", Author: "alice"}, nil
+		},
+	}
+	c, _ := cache.NewLRU(100)
+	s := NewCommentService(mockRepo, c, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
+
+	get := func() map[string][]*domain.Comment {
+		t.Helper()
+		got, err := s.GetRootTreesByPostIDs(context.Background(), []string{"p1", "p2", "empty"}, 2, "")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(got["p1"]) != 1 || len(got["p2"]) != 1 || got["empty"] == nil || len(got["empty"]) != 0 {
+			t.Fatalf("unexpected trees: %+v", got)
+		}
+		return got
+	}
+
+	get()
+	get()
+	if len(loaded) != 1 {
+		t.Fatalf("expected the second lookup to be served from cache, got loads %v", loaded)
+	}
+
+	if _, err := s.Edit(alice, "c-p1", "changed"); err != nil {
+		t.Fatalf("Edit: unexpected error: %v", err)
+	}
+	get()
+	if len(loaded) != 2 || len(loaded[1]) != 1 || loaded[1][0] != "p1" {
+		t.Fatalf("expected only the edited post to be reloaded, got loads %v", loaded)
+	}
+}
+
+// ttlCache records the TTL every key was last set with.
+type ttlCache struct {
+	cache.Cache
+	mu   sync.Mutex
+	ttls map[string]time.Duration
+}
+
+func (c *ttlCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	c.ttls[key] = ttl
+	c.mu.Unlock()
+	return c.Cache.Set(ctx, key, value, ttl)
+}
+
+func TestCommentService_GetRootTreesExpireWithoutHardTTL(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	mockRepo := &mockCommentRepo{
+		getRootsByPostIDsFunc: func(ctx context.Context, postIDs []string, order domain.CommentOrder) ([]*domain.Comment, error) {
+			return []*domain.Comment{{ID: "c1", PostID: "p1"}}, nil
+		},
+	}
+	lru, _ := cache.NewLRU(100)
+	c := &ttlCache{Cache: lru, ttls: make(map[string]time.Duration)}
+	s := NewCommentService(mockRepo, c, CacheTTL{Soft: time.Minute}, nil, nil, authz.DefaultPolicy(), 0, log)
+
+	if _, err := s.GetRootTreesByPostIDs(context.Background(), []string{"p1"}, 2, ""); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(c.ttls) == 0 {
+		t.Fatal("expected the tree to be cached")
+	}
+	for key, ttl := range c.ttls {
+		if ttl != commentsTTL {
+			t.Errorf("expected %s to expire after %v, got %v", key, commentsTTL, ttl)
+		}
+	}
+}
+
+func TestCommentService_GetRootTreesCoalescesLoads(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	mockRepo := &mockCommentRepo{
+		getRootsByPostIDsFunc: func(ctx context.Context, postIDs []string, order domain.CommentOrder) ([]*domain.Comment, error) {
+			loads.Add(1)
+			<-release
+			return []*domain.Comment{{ID: "c1", PostID: "p1"}}, nil
+		},
+	}
+	c, _ := cache.NewLRU(100)
+	s := NewCommentService(mockRepo, c, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := s.GetRootTreesByPostIDs(context.Background(), []string{"p1"}, 2, "")
+			if err != nil || len(got["p1"]) != 1 {
+				t.Errorf("expected one root, got %v, %v", got, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("expected concurrent misses to share one load, got %d", n)
+	}
+}
+
+func TestCommentService_GetThread(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	root, a := "root", "a"
+
+	mockRepo := &mockCommentRepo{
+		getFunc: func(ctx context.Context, id string) (*domain.Comment, error) {
+			return &domain.Comment{ID: id, ReplyCount: 2}, nil
+		},
+		getDescendantsFunc: func(ctx context.Context, parentIDs []string, maxDepth int, order domain.CommentOrder) ([]*domain.Comment, error) {
+			if maxDepth != 1 {
+				t.Fatalf("expected descendants one level deep, got %d", maxDepth)
+			}
+			return []*domain.Comment{
+				{ID: "a", ParentID: &root, ReplyCount: 1},
+				{ID: "b", ParentID: &root},
+			}, nil
+		},
+	}
+
+	s := NewCommentService(mockRepo, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
+
+	t.Run("success", func(t *testing.T) {
+		got, err := s.GetThread(context.Background(), root, 2, "")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got.HasMoreReplies || len(got.Children) != 2 {
+			t.Fatalf("expected root with 2 loaded replies, got %+v", got)
+		}
+		if !got.Children[0].HasMoreReplies || got.Children[0].Children == nil || len(got.Children[0].Children) != 0 {
+			t.Fatalf("expected %s to be truncated, got %+v", a, got.Children[0])
+		}
+		if got.Children[1].HasMoreReplies {
+			t.Fatalf("expected leaf without more replies, got %+v", got.Children[1])
+		}
+	})
+
+	t.Run("invalid depth", func(t *testing.T) {
+		if _, err := s.GetThread(context.Background(), root, 0, ""); err == nil {
+			t.Fatal("expected error for non-positive maxDepth")
+		}
+	})
+
+	t.Run("empty id", func(t *testing.T) {
+		if _, err := s.GetThread(context.Background(), "", 2, ""); err == nil {
+			t.Fatal("expected error for empty id")
+		}
+	})
+}
+
+func TestCommentService_GetRepliesByParentIDs(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	p1, p2, a := "c1", "c2", "a"
+
+	mockRepo := &mockCommentRepo{
+		getDescendantsFunc: func(ctx context.Context, parentIDs []string, maxDepth int, order domain.CommentOrder) ([]*domain.Comment, error) {
+			return []*domain.Comment{
+				{ID: "a", ParentID: &p1, ReplyCount: 1},
+				{ID: "b", ParentID: &p2},
+				{ID: "aa", ParentID: &a, ReplyCount: 3},
+			}, nil
+		},
+	}
+
+	s := NewCommentService(mockRepo, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
+
+	got, err := s.GetRepliesByParentIDs(context.Background(), []string{p1, p2}, 2, "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(got[p1]) != 1 || len(got[p2]) != 1 {
+		t.Fatalf("unexpected grouping: %+v", got)
+	}
+	replyA := got[p1][0]
+	if replyA.HasMoreReplies || len(replyA.Children) != 1 {
+		t.Fatalf("expected a with one loaded reply, got %+v", replyA)
+	}
+	if !replyA.Children[0].HasMoreReplies {
+		t.Fatalf("expected aa to be truncated, got %+v", replyA.Children[0])
+	}
+}
+
+func TestCommentService_Edit(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	author := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice"})
+	deletedAt := time.Now()
+
+	var updated string
+	mockRepo := &mockCommentRepo{
+		getFunc: func(ctx context.Context, id string) (*domain.Comment, error) {
+			c := &domain.Comment{ID: id, Author: "alice", Text: "old"}
+			if id == "deleted" {
+				c.DeletedAt = &deletedAt
+			}
+			if updated != "" {
+				c.Text, c.Edited = updated, true
+			}
+			return c, nil
+		},
+		updateTextFunc: func(ctx context.Context, id, text string) error {
+			updated = text
+			return nil
+		},
+	}
+
+	s := NewCommentService(mockRepo, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
+
+	t.Run("author", func(t *testing.T) {
+		got, err := s.Edit(author, "c1", "new")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got.Text != "new" || !got.Edited {
+			t.Fatalf("expected edited comment, got %+v", got)
+		}
+	})
+
+	t.Run("other user", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "bob", Roles: []string{authz.RoleModerator}})
+		if _, err := s.Edit(ctx, "c1", "new"); !errors.Is(err, domain.ErrForbidden) {
+			t.Fatalf("expected ErrForbidden, got %v", err)
+		}
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		if _, err := s.Edit(context.Background(), "c1", "new"); !errors.Is(err, auth.ErrUnauthenticated) {
+			t.Fatalf("expected ErrUnauthenticated, got %v", err)
+		}
+	})
+
+	t.Run("deleted comment", func(t *testing.T) {
+		if _, err := s.Edit(author, "deleted", "new"); !errors.Is(err, domain.ErrConflict) {
+			t.Fatalf("expected ErrConflict, got %v", err)
+		}
+	})
+
+	t.Run("empty text", func(t *testing.T) {
+		if _, err := s.Edit(author, "c1", ""); err == nil {
+			t.Fatal("expected error for empty text")
+		}
+	})
+}
+
+func TestCommentService_Delete(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	author := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice"})
+
+	var softDeleted []string
+	mockRepo := &mockCommentRepo{
+		getFunc: func(ctx context.Context, id string) (*domain.Comment, error) {
+			return &domain.Comment{ID: id, Author: "alice"}, nil
+		},
+		deleteLeafFunc: func(ctx context.Context, id string) (bool, error) {
+			return id == "leaf", nil
+		},
+		softDeleteFunc: func(ctx context.Context, id string) error {
+			softDeleted = append(softDeleted, id)
+			return nil
+		},
+	}
+
+	s := NewCommentService(mockRepo, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
+
+	t.Run("leaf is removed", func(t *testing.T) {
+		softDeleted = nil
+		if err := s.Delete(author, "leaf"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(softDeleted) != 0 {
+			t.Fatalf("expected no tombstone, got %v", softDeleted)
+		}
+	})
+
+	t.Run("comment with replies becomes a tombstone", func(t *testing.T) {
+		softDeleted = nil
+		if err := s.Delete(author, "parent"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(softDeleted) != 1 || softDeleted[0] != "parent" {
+			t.Fatalf("expected parent to be soft deleted, got %v", softDeleted)
+		}
+	})
+
+	t.Run("other user", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "bob"})
+		if err := s.Delete(ctx, "leaf"); !errors.Is(err, domain.ErrForbidden) {
+			t.Fatalf("expected ErrForbidden, got %v", err)
+		}
+	})
+}
+
+func TestCommentService_PublishesEvents(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	author := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice"})
+
+	mockRepo := &mockCommentRepo{
+		getFunc: func(ctx context.Context, id string) (*domain.Comment, error) {
+			return &domain.Comment{ID: id, PostID: "1", Author: "alice", Text: "text of " + id}, nil
+		},
+		deleteLeafFunc: func(ctx context.Context, id string) (bool, error) {
+			return id == "leaf", nil
+		},
+	}
+	mockPostRepo := &mockPostRepo{
+		getFunc: func(ctx context.Context, postId string) (*domain.Post, error) {
+			return &domain.Post{ID: postId, Flag: true}, nil
+		},
+	}
+	bus, evs := subscribeEvents(t, "1")
+	s := NewCommentService(mockRepo, nil, testCacheTTL, bus, mockPostRepo, authz.DefaultPolicy(), 0, log)
+
+	comment := &domain.Comment{PostID: "1", Author: "alice", Text: "hello"}
+	if err := s.Create(author, comment); err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	if e := expectEvent(t, evs, events.CommentAdded); e.Comment == nil || e.Comment.ID != comment.ID {
+		t.Fatalf("expected the new comment, got %+v", e)
+	}
+
+	if _, err := s.Edit(author, "c1", "changed"); err != nil {
+		t.Fatalf("Edit: unexpected error: %v", err)
+	}
+	if e := expectEvent(t, evs, events.CommentEdited); e.Comment == nil || e.Comment.ID != "c1" {
+		t.Fatalf("expected the edited comment, got %+v", e)
+	}
+
+	if err := s.Delete(author, "leaf"); err != nil {
+		t.Fatalf("Delete: unexpected error: %v", err)
+	}
+	if e := expectEvent(t, evs, events.CommentDeleted); e.CommentID != "leaf" || e.Comment != nil {
+		t.Fatalf("expected leaf to be removed, got %+v", e)
+	}
+
+	if err := s.Delete(author, "parent"); err != nil {
+		t.Fatalf("Delete: unexpected error: %v", err)
+	}
+	if e := expectEvent(t, evs, events.CommentDeleted); e.CommentID != "parent" || e.Comment == nil {
+		t.Fatalf("expected parent to become a tombstone, got %+v", e)
+	}
+
+	if _, err := s.Vote(author, "c2", domain.VoteUp); err != nil {
+		t.Fatalf("Vote: unexpected error: %v", err)
+	}
+	if e := expectEvent(t, evs, events.CommentVoted); e.Comment == nil || e.Comment.ID != "c2" {
+		t.Fatalf("expected the voted comment, got %+v", e)
+	}
+
+	moderator := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "mod", Roles: []string{authz.RoleModerator}})
+	if _, err := s.Lock(moderator, "c3", true); err != nil {
+		t.Fatalf("Lock: unexpected error: %v", err)
+	}
+	if e := expectEvent(t, evs, events.CommentLocked); e.Comment == nil || e.Comment.ID != "c3" {
+		t.Fatalf("expected the locked comment, got %+v", e)
+	}
+}
+
+func TestCommentService_Vote(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	voter := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "bob"})
+
+	var voted domain.VoteValue
+	mockRepo := &mockCommentRepo{
+		getFunc: func(ctx context.Context, id string) (*domain.Comment, error) {
+			return &domain.Comment{ID: id, Author: "alice", Upvotes: 1}, nil
+		},
+		setVoteFunc: func(ctx context.Context, commentID, userID string, value domain.VoteValue) error {
+			if userID != "bob" {
+				t.Fatalf("expected vote by bob, got %q", userID)
+			}
+			voted = value
+			return nil
+		},
+	}
+
+	s := NewCommentService(mockRepo, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
+
+	t.Run("success", func(t *testing.T) {
+		got, err := s.Vote(voter, "c1", domain.VoteUp)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if voted != domain.VoteUp || got.Upvotes != 1 {
+			t.Fatalf("expected upvote to be stored, got %v and %+v", voted, got)
+		}
+	})
+
+	t.Run("unknown value", func(t *testing.T) {
+		if _, err := s.Vote(voter, "c1", 2); err == nil {
+			t.Fatal("expected error for unknown vote value")
+		}
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		if _, err := s.Vote(context.Background(), "c1", domain.VoteUp); !errors.Is(err, auth.ErrUnauthenticated) {
+			t.Fatalf("expected ErrUnauthenticated, got %v", err)
+		}
+	})
+}
+
+func TestCommentService_Lock(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	comment := &domain.Comment{ID: "c1", Author: "alice"}
+	mockRepo := &mockCommentRepo{
+		getFunc: func(ctx context.Context, id string) (*domain.Comment, error) {
+			return comment, nil
+		},
+		setLockedFunc: func(ctx context.Context, id string, locked bool) error {
+			comment.Locked = locked
+			return nil
+		},
+	}
+
+	s := NewCommentService(mockRepo, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
+
+	t.Run("moderator", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "bob", Roles: []string{authz.RoleModerator}})
+		got, err := s.Lock(ctx, "c1", true)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !got.Locked {
+			t.Fatal("expected comment to be locked")
+		}
+	})
+
+	t.Run("author", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice"})
+		if _, err := s.Lock(ctx, "c1", false); !errors.Is(err, domain.ErrForbidden) {
+			t.Fatalf("expected ErrForbidden, got %v", err)
+		}
+	})
+}
+
+func TestCommentService_GetMyVotes(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	mockRepo := &mockCommentRepo{
+		getVotesFunc: func(ctx context.Context, userID string, commentIDs []string) (map[string]domain.VoteValue, error) {
+			return map[string]domain.VoteValue{"c1": domain.VoteDown}, nil
+		},
+	}
+
+	s := NewCommentService(mockRepo, nil, testCacheTTL, nil, nil, authz.DefaultPolicy(), 0, log)
+
+	t.Run("signed in", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "bob"})
+		got, err := s.GetMyVotes(ctx, []string{"c1", "c2"})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got["c1"] != domain.VoteDown || got["c2"] != domain.VoteNone {
+			t.Fatalf("unexpected votes: %v", got)
+		}
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		got, err := s.GetMyVotes(context.Background(), []string{"c1"})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(got) != 0 {
+			t.Fatalf("expected no votes, got %v", got)
+		}
+	})
+}
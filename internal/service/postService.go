@@ -2,39 +2,46 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"log/slog"
-	"time"
 
 	"github.com/google/uuid"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/authz"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/cache"
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/events"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pagination"
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/repository"
-	"github.com/redis/go-redis/v9"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/tracing"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/validation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type PostService struct {
-	repo        repository.PostRepository
-	commentRepo repository.CommentRepository
-	redis       *redis.Client
-	log         *slog.Logger
+	repo   repository.PostRepository
+	cache  *readThrough
+	events *events.Bus
+	policy *authz.Policy
+	log    *slog.Logger
 }
 
-func NewPostService(repo repository.PostRepository, commentRepo repository.CommentRepository, redis *redis.Client, log *slog.Logger) *PostService {
-	return &PostService{repo: repo, commentRepo: commentRepo, redis: redis, log: log}
+func NewPostService(repo repository.PostRepository, cache cache.Cache, cacheTTL CacheTTL, bus *events.Bus, policy *authz.Policy, log *slog.Logger) *PostService {
+	return &PostService{repo: repo, cache: newReadThrough(cache, cacheTTL, log), events: bus, policy: policy, log: log}
 }
 
-func (p *PostService) Create(ctx context.Context, post *domain.Post) error {
+func (p *PostService) Create(ctx context.Context, post *domain.Post) (err error) {
+	ctx, span := tracer.Start(ctx, "PostService.Create")
+	defer tracing.End(span, &err)
+
 	if post == nil {
 		err := errors.New("post is nil")
 		p.log.Error("Create post failed", "error", err)
 		return err
 	}
 
-	if post.Title == "" || post.Content == "" || post.Author == "" {
-		err := errors.New("title, content and author are required")
-		p.log.Error("Create post failed", "error", err)
-		return nil
+	if err := validation.Post(post); err != nil {
+		p.log.Warn("Create post failed", "error", err)
+		return err
 	}
 
 	post.Flag = true
@@ -48,153 +55,249 @@ func (p *PostService) Create(ctx context.Context, post *domain.Post) error {
 		return err
 	}
 
-	if p.redis != nil {
-		if err := p.redis.Del(ctx, "posts:list").Err(); err != nil {
-			p.log.Error("failed del from redis", "error", err)
-		}
-	}
+	p.cache.invalidate(ctx, postsListKey)
 
 	return nil
 }
 
-func (p *PostService) Get(ctx context.Context, postId string) (*domain.Post, error) {
+func (p *PostService) Get(ctx context.Context, postId string) (_ *domain.Post, err error) {
+	ctx, span := tracer.Start(ctx, "PostService.Get", trace.WithAttributes(tracing.ID("post", postId)))
+	defer tracing.End(span, &err)
+
 	if postId == "" {
-		err := errors.New("postId is required")
+		err := domain.Validation("postId is required")
 		p.log.Error("Get post failed", "error", err)
 		return nil, err
 	}
 
-	cacheKey := "post:" + postId
-
-	if p.redis != nil {
-		cached, err := p.redis.Get(ctx, cacheKey).Result()
-		if err == nil {
-			var post domain.Post
-			if err := json.Unmarshal([]byte(cached), &post); err == nil {
-				return &post, nil
-			}
+	return getOrLoad(ctx, p.cache, "post", postKey(postId), func(ctx context.Context) (*domain.Post, error) {
+		post, err := p.repo.Get(ctx, postId)
+		if err != nil {
+			p.log.Error("failed Get post repo", "error", err)
+			return nil, err
 		}
+		return post, nil
+	})
+}
+
+func (p *PostService) GetList(ctx context.Context) (_ []*domain.Post, err error) {
+	ctx, span := tracer.Start(ctx, "PostService.GetList")
+	defer tracing.End(span, &err)
+
+	if !p.cache.enabled() {
+		return p.getList(ctx)
 	}
 
-	post, err := p.repo.Get(ctx, postId)
+	ids, err := getOrLoad(ctx, p.cache, "posts_list", postsListKey, func(ctx context.Context) ([]string, error) {
+		began := p.cache.begin()
+		posts, err := p.getList(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(posts))
+		for _, post := range posts {
+			ids = append(ids, post.ID)
+			p.cache.store(ctx, postKey(post.ID), post, began)
+		}
+		return ids, nil
+	})
 	if err != nil {
-		p.log.Error("failed Get post repo", "error", err)
 		return nil, err
 	}
 
-	comments, err := p.commentRepo.GetByPostID(ctx, post.ID)
+	byID, err := getMany(ctx, p.cache, "post", ids, postKey, func(ctx context.Context, ids []string) (map[string]*domain.Post, error) {
+		posts, err := p.repo.GetByIDs(ctx, ids)
+		if err != nil {
+			p.log.Error("failed get posts by ids repo", "error", err)
+			return nil, err
+		}
+		byID := make(map[string]*domain.Post, len(posts))
+		for _, post := range posts {
+			byID[post.ID] = post
+		}
+		return byID, nil
+	})
 	if err != nil {
-		p.log.Error("failed get cooments of post repo", "error", err)
 		return nil, err
 	}
-	post.Comments = buildCommentTree(comments)
 
-	if p.redis != nil {
-		bytes, _ := json.Marshal(post)
-		if err = p.redis.Set(ctx, cacheKey, bytes, time.Minute*5).Err(); err != nil {
-			p.log.Error("failed set to redis", "error", err)
+	posts := make([]*domain.Post, 0, len(ids))
+	for _, id := range ids {
+		if post, ok := byID[id]; ok {
+			posts = append(posts, post)
 		}
 	}
+	return posts, nil
+}
 
-	return post, nil
+func (p *PostService) getList(ctx context.Context) ([]*domain.Post, error) {
+	posts, err := p.repo.GetList(ctx)
+	if err != nil {
+		p.log.Error("failed get list repo", "error", err)
+		return nil, err
+	}
+	return posts, nil
 }
 
-func (p *PostService) GetList(ctx context.Context) ([]*domain.Post, error) {
-	cacheKey := "posts:list"
+func (p *PostService) SetFlag(ctx context.Context, postId string, flag bool) (err error) {
+	ctx, span := tracer.Start(ctx, "PostService.SetFlag", trace.WithAttributes(tracing.ID("post", postId)))
+	defer tracing.End(span, &err)
 
-	if p.redis != nil {
-		cached, err := p.redis.Get(ctx, cacheKey).Result()
-		if err == nil {
-			var posts []*domain.Post
-			if err := json.Unmarshal([]byte(cached), &posts); err == nil {
-				return posts, nil
-			}
-		}
+	if postId == "" {
+		err := domain.Validation("postId is required")
+		p.log.Error("failed switch flag", "error", err)
+		return err
 	}
 
-	posts, err := p.repo.GetList(ctx)
+	if _, err := p.authorize(ctx, authz.ActionToggleComments, postId); err != nil {
+		return err
+	}
+
+	if err := p.repo.SetFlag(ctx, postId, flag); err != nil {
+		p.log.Error("failed update flag repo", "error", err)
+		return err
+	}
+
+	p.invalidate(ctx, postId)
+	p.events.Publish(ctx, events.Event{Type: events.CommentsToggled, PostID: postId, CommentsAllowed: flag})
+	return nil
+}
+
+// Update replaces the title and content of one of the caller's posts. The
+// previous version is kept as a revision.
+func (p *PostService) Update(ctx context.Context, postId, title, content string) (_ *domain.Post, err error) {
+	ctx, span := tracer.Start(ctx, "PostService.Update", trace.WithAttributes(tracing.ID("post", postId)))
+	defer tracing.End(span, &err)
+
+	if postId == "" {
+		err := domain.Validation("postId is required")
+		p.log.Error("failed update post", "error", err)
+		return nil, err
+	}
+
+	post, err := p.authorize(ctx, authz.ActionEditPost, postId)
 	if err != nil {
-		p.log.Error("failed get list repo", "error", err)
 		return nil, err
 	}
 
-	if len(posts) == 0 {
-		return posts, nil
+	updated := *post
+	updated.Title, updated.Content = title, content
+	if err := validation.Post(&updated); err != nil {
+		p.log.Warn("failed update post", "postId", postId, "error", err)
+		return nil, err
 	}
 
-	postIDs := make([]string, 0, len(posts))
-	for _, post := range posts {
-		postIDs = append(postIDs, post.ID)
+	return p.update(ctx, post, updated.Title, updated.Content)
+}
+
+// Revert makes an earlier revision the current version of the post. The
+// history is kept: the version being replaced becomes a new revision.
+func (p *PostService) Revert(ctx context.Context, postId string, revision int) (_ *domain.Post, err error) {
+	ctx, span := tracer.Start(ctx, "PostService.Revert", trace.WithAttributes(tracing.ID("post", postId)))
+	defer tracing.End(span, &err)
+
+	if postId == "" {
+		err := domain.Validation("postId is required")
+		p.log.Error("failed revert post", "error", err)
+		return nil, err
 	}
 
-	comments, err := p.commentRepo.GetByPostIDs(ctx, postIDs)
+	post, err := p.authorize(ctx, authz.ActionEditPost, postId)
 	if err != nil {
-		p.log.Error("failed get comments for posts repo", "error", err)
 		return nil, err
 	}
 
-	commentsByPost := make(map[string][]*domain.Comment)
-	for _, c := range comments {
-		commentsByPost[c.PostID] = append(commentsByPost[c.PostID], c)
+	rev, err := p.repo.GetRevision(ctx, postId, revision)
+	if err != nil {
+		p.log.Error("failed get revision repo", "postId", postId, "revision", revision, "error", err)
+		return nil, err
 	}
 
-	for _, post := range posts {
-		post.Comments = buildCommentTree(commentsByPost[post.ID])
-	}
+	return p.update(ctx, post, rev.Title, rev.Content)
+}
 
-	if p.redis != nil {
-		bytes, _ := json.Marshal(posts)
-		if err := p.redis.Set(ctx, cacheKey, bytes, time.Minute*5).Err(); err != nil {
-			p.log.Error("failed set туту епт redis", "error", err)
-		}
+func (p *PostService) GetRevisionsByPostIDs(ctx context.Context, postIDs []string) (_ map[string][]*domain.PostRevision, err error) {
+	ctx, span := tracer.Start(ctx, "PostService.GetRevisionsByPostIDs")
+	defer tracing.End(span, &err)
+
+	revisions, err := p.repo.GetRevisionsByPostIDs(ctx, postIDs)
+	if err != nil {
+		p.log.Error("failed get revisions repo", "error", err)
+		return nil, err
 	}
 
-	return posts, nil
+	byPost := make(map[string][]*domain.PostRevision, len(postIDs))
+	for _, rev := range revisions {
+		byPost[rev.PostID] = append(byPost[rev.PostID], rev)
+	}
+	return byPost, nil
 }
 
-func (p *PostService) SetFlag(ctx context.Context, postId string, flag bool) error {
-	if postId == "" {
-		err := errors.New("postId is required")
-		p.log.Error("failed switch flag", "error", err)
-		return err
+func (p *PostService) update(ctx context.Context, post *domain.Post, title, content string) (*domain.Post, error) {
+	if post.Title == title && post.Content == content {
+		return post, nil
 	}
 
-	if err := p.repo.SetFlag(ctx, postId, flag); err != nil {
-		p.log.Error("failed update flag repo", "error", err)
-		return err
+	if err := p.repo.Update(ctx, post.ID, title, content); err != nil {
+		p.log.Error("failed update post repo", "error", err)
+		return nil, err
 	}
+	p.invalidate(ctx, post.ID)
 
-	if p.redis != nil {
-		if err := p.redis.Del(ctx, "post:"+postId).Err(); err != nil {
-			p.log.Warn("failed del in redis func:SetFlag", "error", err)
-		}
-		if err := p.redis.Del(ctx, "posts:list").Err(); err != nil {
-			p.log.Warn("failed del in redis func:SetFlag", "error", err)
-		}
+	updated, err := p.repo.Get(ctx, post.ID)
+	if err != nil {
+		p.log.Error("failed get post repo", "error", err)
+		return nil, err
 	}
+	p.events.Publish(ctx, events.Event{Type: events.PostUpdated, PostID: post.ID, Post: updated})
+	return updated, nil
+}
 
-	return nil
+// authorize loads the post bypassing the cache and checks that the caller
+// may perform action on it.
+func (p *PostService) authorize(ctx context.Context, action authz.Action, postId string) (*domain.Post, error) {
+	post, err := p.repo.Get(ctx, postId)
+	if err != nil {
+		p.log.Error("failed get post repo", "error", err)
+		return nil, err
+	}
+	if err := p.policy.Authorize(ctx, action, authz.Resource{OwnerID: post.Author}); err != nil {
+		p.log.Warn("post action denied", "action", action, "postId", postId, "error", err)
+		return nil, err
+	}
+	return post, nil
 }
 
-func buildCommentTree(comments []*domain.Comment) []*domain.Comment {
-	byParent := make(map[string][]*domain.Comment)
+// invalidate drops the cached copy of a changed post. The list only holds
+// ids, so it stays.
+func (p *PostService) invalidate(ctx context.Context, postId string) {
+	p.cache.invalidate(ctx, postKey(postId))
+}
 
-	for _, c := range comments {
-		parentID := ""
-		if c.ParentID != nil {
-			parentID = *c.ParentID
-		}
-		byParent[parentID] = append(byParent[parentID], c)
+func (p *PostService) GetPage(ctx context.Context, filter domain.PostFilter, order domain.PostOrder, args pagination.Args) (_ pagination.Page[*domain.Post], err error) {
+	ctx, span := tracer.Start(ctx, "PostService.GetPage")
+	defer tracing.End(span, &err)
+
+	switch order {
+	case "":
+		order = domain.PostOrderNewest
+	case domain.PostOrderNewest, domain.PostOrderOldest:
+	default:
+		err := domain.Validation("unknown posts order")
+		p.log.Error("failed get posts page", "error", err, "order", order)
+		return pagination.Page[*domain.Post]{}, err
 	}
 
-	var attach func(parentID string) []*domain.Comment
-	attach = func(parentID string) []*domain.Comment {
-		children := byParent[parentID]
-		for _, c := range children {
-			c.Children = attach(c.ID)
-		}
-		return children
+	window, err := args.Window()
+	if err != nil {
+		return pagination.Page[*domain.Post]{}, err
+	}
+
+	posts, err := p.repo.GetPage(ctx, filter, order, window)
+	if err != nil {
+		p.log.Error("failed get posts page repo", "error", err)
+		return pagination.Page[*domain.Post]{}, err
 	}
 
-	return attach("")
+	return pagination.NewPage(posts, window), nil
 }
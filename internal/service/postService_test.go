@@ -7,15 +7,61 @@ import (
 	"testing"
 
 	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/auth"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/authz"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/cache"
 	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/events"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pagination"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/pubsub"
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/validation"
 )
 
+var testCacheTTL = CacheTTL{Soft: time.Minute, Hard: 5 * time.Minute}
+
+// subscribeEvents returns a bus and the events it carries for postID.
+func subscribeEvents(t *testing.T, postID string) (*events.Bus, <-chan events.Event) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	bus := events.NewBus(pubsub.NewMemoryBroker(), events.NewMemoryLog(100), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	evs, err := bus.Subscribe(ctx, postID, nil)
+	if err != nil {
+		t.Fatalf("Subscribe: unexpected error: %v", err)
+	}
+	return bus, evs
+}
+
+func expectEvent(t *testing.T, evs <-chan events.Event, typ events.Type) events.Event {
+	t.Helper()
+	select {
+	case e := <-evs:
+		if e.Type != typ {
+			t.Fatalf("expected a %s event, got %+v", typ, e)
+		}
+		return e
+	case <-time.After(time.Second):
+		t.Fatalf("expected a %s event", typ)
+		return events.Event{}
+	}
+}
+
 type mockPostRepo struct {
-	createFunc  func(ctx context.Context, post *domain.Post) error
-	getFunc     func(ctx context.Context, postId string) (*domain.Post, error)
-	getListFunc func(ctx context.Context) ([]*domain.Post, error)
-	setFlagFunc func(ctx context.Context, postId string, flag bool) error
+	createFunc   func(ctx context.Context, post *domain.Post) error
+	getFunc      func(ctx context.Context, postId string) (*domain.Post, error)
+	getListFunc  func(ctx context.Context) ([]*domain.Post, error)
+	getByIDsFunc func(ctx context.Context, ids []string) ([]*domain.Post, error)
+	setFlagFunc  func(ctx context.Context, postId string, flag bool) error
+	getPageFunc  func(ctx context.Context, filter domain.PostFilter, order domain.PostOrder, window pagination.Window) ([]*domain.Post, error)
+	updateFunc   func(ctx context.Context, postId, title, content string) error
+
+	getRevisionFunc           func(ctx context.Context, postId string, revision int) (*domain.PostRevision, error)
+	getRevisionsByPostIDsFunc func(ctx context.Context, postIDs []string) ([]*domain.PostRevision, error)
 }
 
 func (m *mockPostRepo) Create(ctx context.Context, post *domain.Post) error {
@@ -36,6 +82,12 @@ func (m *mockPostRepo) GetList(ctx context.Context) ([]*domain.Post, error) {
 	}
 	return nil, nil
 }
+func (m *mockPostRepo) GetByIDs(ctx context.Context, ids []string) ([]*domain.Post, error) {
+	if m.getByIDsFunc != nil {
+		return m.getByIDsFunc(ctx, ids)
+	}
+	return nil, nil
+}
 func (m *mockPostRepo) SetFlag(ctx context.Context, postId string, flag bool) error {
 	if m.setFlagFunc != nil {
 		return m.setFlagFunc(ctx, postId, flag)
@@ -43,10 +95,50 @@ func (m *mockPostRepo) SetFlag(ctx context.Context, postId string, flag bool) er
 	return nil
 }
 
+func (m *mockPostRepo) GetPage(ctx context.Context, filter domain.PostFilter, order domain.PostOrder, window pagination.Window) ([]*domain.Post, error) {
+	if m.getPageFunc != nil {
+		return m.getPageFunc(ctx, filter, order, window)
+	}
+	return nil, nil
+}
+
+func (m *mockPostRepo) Update(ctx context.Context, postId, title, content string) error {
+	if m.updateFunc != nil {
+		return m.updateFunc(ctx, postId, title, content)
+	}
+	return nil
+}
+
+func (m *mockPostRepo) GetRevision(ctx context.Context, postId string, revision int) (*domain.PostRevision, error) {
+	if m.getRevisionFunc != nil {
+		return m.getRevisionFunc(ctx, postId, revision)
+	}
+	return nil, nil
+}
+
+func (m *mockPostRepo) GetRevisionsByPostIDs(ctx context.Context, postIDs []string) ([]*domain.PostRevision, error) {
+	if m.getRevisionsByPostIDsFunc != nil {
+		return m.getRevisionsByPostIDsFunc(ctx, postIDs)
+	}
+	return nil, nil
+}
+
 type mockCommentRepo struct {
-	createFunc       func(ctx context.Context, comment *domain.Comment) error
-	getByPostIDFunc  func(ctx context.Context, postID string) ([]*domain.Comment, error)
-	getByPostIDsFunc func(ctx context.Context, postIDs []string) ([]*domain.Comment, error)
+	createFunc            func(ctx context.Context, comment *domain.Comment) error
+	getByPostIDFunc       func(ctx context.Context, postID string) ([]*domain.Comment, error)
+	getByPostIDsFunc      func(ctx context.Context, postIDs []string) ([]*domain.Comment, error)
+	getRootsByPostIDFunc  func(ctx context.Context, postID string, filter domain.CommentFilter, order domain.CommentOrder, limit, offset int) ([]*domain.Comment, error)
+	getRootsPageFunc      func(ctx context.Context, postID string, filter domain.CommentFilter, window pagination.Window) ([]*domain.Comment, error)
+	getRootsByPostIDsFunc func(ctx context.Context, postIDs []string, order domain.CommentOrder) ([]*domain.Comment, error)
+	getByParentIDsFunc    func(ctx context.Context, parentIDs []string, order domain.CommentOrder) ([]*domain.Comment, error)
+	getFunc               func(ctx context.Context, id string) (*domain.Comment, error)
+	getDescendantsFunc    func(ctx context.Context, parentIDs []string, maxDepth int, order domain.CommentOrder) ([]*domain.Comment, error)
+	updateTextFunc        func(ctx context.Context, id, text string) error
+	softDeleteFunc        func(ctx context.Context, id string) error
+	setLockedFunc         func(ctx context.Context, id string, locked bool) error
+	deleteLeafFunc        func(ctx context.Context, id string) (bool, error)
+	setVoteFunc           func(ctx context.Context, commentID, userID string, value domain.VoteValue) error
+	getVotesFunc          func(ctx context.Context, userID string, commentIDs []string) (map[string]domain.VoteValue, error)
 }
 
 func (m *mockCommentRepo) Create(ctx context.Context, comment *domain.Comment) error {
@@ -69,6 +161,90 @@ func (m *mockCommentRepo) GetByPostIDs(ctx context.Context, postIDs []string) ([
 	return nil, nil
 }
 
+func (m *mockCommentRepo) GetRootsByPostID(ctx context.Context, postID string, filter domain.CommentFilter, order domain.CommentOrder, limit, offset int) ([]*domain.Comment, error) {
+	if m.getRootsByPostIDFunc != nil {
+		return m.getRootsByPostIDFunc(ctx, postID, filter, order, limit, offset)
+	}
+	return nil, nil
+}
+
+func (m *mockCommentRepo) GetRootsPage(ctx context.Context, postID string, filter domain.CommentFilter, window pagination.Window) ([]*domain.Comment, error) {
+	if m.getRootsPageFunc != nil {
+		return m.getRootsPageFunc(ctx, postID, filter, window)
+	}
+	return nil, nil
+}
+
+func (m *mockCommentRepo) GetRootsByPostIDs(ctx context.Context, postIDs []string, order domain.CommentOrder) ([]*domain.Comment, error) {
+	if m.getRootsByPostIDsFunc != nil {
+		return m.getRootsByPostIDsFunc(ctx, postIDs, order)
+	}
+	return nil, nil
+}
+
+func (m *mockCommentRepo) GetByParentIDs(ctx context.Context, parentIDs []string, order domain.CommentOrder) ([]*domain.Comment, error) {
+	if m.getByParentIDsFunc != nil {
+		return m.getByParentIDsFunc(ctx, parentIDs, order)
+	}
+	return nil, nil
+}
+
+func (m *mockCommentRepo) Get(ctx context.Context, id string) (*domain.Comment, error) {
+	if m.getFunc != nil {
+		return m.getFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (m *mockCommentRepo) GetDescendants(ctx context.Context, parentIDs []string, maxDepth int, order domain.CommentOrder) ([]*domain.Comment, error) {
+	if m.getDescendantsFunc != nil {
+		return m.getDescendantsFunc(ctx, parentIDs, maxDepth, order)
+	}
+	return nil, nil
+}
+
+func (m *mockCommentRepo) UpdateText(ctx context.Context, id, text string) error {
+	if m.updateTextFunc != nil {
+		return m.updateTextFunc(ctx, id, text)
+	}
+	return nil
+}
+
+func (m *mockCommentRepo) SoftDelete(ctx context.Context, id string) error {
+	if m.softDeleteFunc != nil {
+		return m.softDeleteFunc(ctx, id)
+	}
+	return nil
+}
+
+func (m *mockCommentRepo) SetLocked(ctx context.Context, id string, locked bool) error {
+	if m.setLockedFunc != nil {
+		return m.setLockedFunc(ctx, id, locked)
+	}
+	return nil
+}
+
+func (m *mockCommentRepo) DeleteLeaf(ctx context.Context, id string) (bool, error) {
+	if m.deleteLeafFunc != nil {
+		return m.deleteLeafFunc(ctx, id)
+	}
+	return false, nil
+}
+
+func (m *mockCommentRepo) SetVote(ctx context.Context, commentID, userID string, value domain.VoteValue) error {
+	if m.setVoteFunc != nil {
+		return m.setVoteFunc(ctx, commentID, userID, value)
+	}
+	return nil
+}
+
+func (m *mockCommentRepo) GetVotes(ctx context.Context, userID string, commentIDs []string) (map[string]domain.VoteValue, error) {
+	if m.getVotesFunc != nil {
+		return m.getVotesFunc(ctx, userID, commentIDs)
+	}
+	return nil, nil
+}
+
 func TestPostService_Create(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
 
@@ -78,7 +254,7 @@ func TestPostService_Create(t *testing.T) {
 				return nil
 			},
 		}
-		s := NewPostService(mockRepo, nil, nil, logger)
+		s := NewPostService(mockRepo, nil, testCacheTTL, nil, authz.DefaultPolicy(), logger)
 		p := &domain.Post{Title: "test", Content: "test", Author: "test"}
 		if err := s.Create(context.Background(), p); err != nil {
 			t.Fatalf("expected no error, got %v", err)
@@ -90,7 +266,7 @@ func TestPostService_Create(t *testing.T) {
 
 	t.Run("nil post", func(t *testing.T) {
 		mockRepo := &mockPostRepo{}
-		s := NewPostService(mockRepo, nil, nil, logger)
+		s := NewPostService(mockRepo, nil, testCacheTTL, nil, authz.DefaultPolicy(), logger)
 		err := s.Create(context.Background(), nil)
 		if err == nil {
 			t.Fatal("expected error for nil post")
@@ -99,10 +275,14 @@ func TestPostService_Create(t *testing.T) {
 
 	t.Run("missing fields", func(t *testing.T) {
 		mockRepo := &mockPostRepo{}
-		s := NewPostService(mockRepo, nil, nil, logger)
+		s := NewPostService(mockRepo, nil, testCacheTTL, nil, authz.DefaultPolicy(), logger)
 		err := s.Create(context.Background(), &domain.Post{Title: "", Content: "", Author: ""})
-		if err != nil {
-			t.Fatal("Create should return nil for empty fields in this implementation")
+		var fields validation.Errors
+		if !errors.As(err, &fields) || !errors.Is(err, domain.ErrValidation) {
+			t.Fatalf("expected validation errors, got %v", err)
+		}
+		if len(fields) != 3 {
+			t.Fatalf("expected errors for title, content and author, got %v", fields)
 		}
 	})
 }
@@ -111,22 +291,14 @@ func TestPostService_Get(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
 	ctx := context.Background()
 	post := &domain.Post{ID: "1", Title: "test", Content: "test", Author: "test"}
-	comments := []*domain.Comment{
-		{ID: "c1", PostID: "1", Text: "hello"},
-	}
 
 	mockRepo := &mockPostRepo{
 		getFunc: func(ctx context.Context, postId string) (*domain.Post, error) {
 			return post, nil
 		},
 	}
-	mockComments := &mockCommentRepo{
-		getByPostIDFunc: func(ctx context.Context, postID string) ([]*domain.Comment, error) {
-			return comments, nil
-		},
-	}
 
-	s := NewPostService(mockRepo, mockComments, nil, logger)
+	s := NewPostService(mockRepo, nil, testCacheTTL, nil, authz.DefaultPolicy(), logger)
 
 	t.Run("success", func(t *testing.T) {
 		got, err := s.Get(ctx, "1")
@@ -136,9 +308,6 @@ func TestPostService_Get(t *testing.T) {
 		if got.ID != "1" {
 			t.Errorf("expected ID '1', got %s", got.ID)
 		}
-		if len(got.Comments) != 1 {
-			t.Errorf("expected 1 comment, got %d", len(got.Comments))
-		}
 	})
 
 	t.Run("empty postId", func(t *testing.T) {
@@ -149,6 +318,232 @@ func TestPostService_Get(t *testing.T) {
 	})
 }
 
+func TestPostService_GetCached(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	owner := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice"})
+
+	post := &domain.Post{ID: "1", Title: "test", Content: "test", Author: "alice", Revision: 1}
+	loads := 0
+	mockRepo := &mockPostRepo{
+		getFunc: func(ctx context.Context, postId string) (*domain.Post, error) {
+			loads++
+			copied := *post
+			return &copied, nil
+		},
+		updateFunc: func(ctx context.Context, postId, title, content string) error {
+			post.Title, post.Content = title, content
+			post.Revision++
+			return nil
+		},
+	}
+	c, err := cache.NewLRU(10)
+	if err != nil {
+		t.Fatalf("NewLRU: unexpected error: %v", err)
+	}
+	s := NewPostService(mockRepo, c, testCacheTTL, nil, authz.DefaultPolicy(), logger)
+
+	for range 2 {
+		if _, err := s.Get(owner, "1"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("expected the second Get to be served from cache, got %d loads", loads)
+	}
+
+	if _, err := s.Update(owner, "1", "changed", "test"); err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+	got, err := s.Get(owner, "1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Title != "changed" {
+		t.Fatalf("expected the update to invalidate the cache, got title %q", got.Title)
+	}
+}
+
+func TestPostService_GetCoalescesLoads(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	mockRepo := &mockPostRepo{
+		getListFunc: func(ctx context.Context) ([]*domain.Post, error) {
+			loads.Add(1)
+			<-release
+			return []*domain.Post{{ID: "1"}}, nil
+		},
+	}
+	c, _ := cache.NewLRU(10)
+	s := NewPostService(mockRepo, c, testCacheTTL, nil, authz.DefaultPolicy(), logger)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if posts, err := s.GetList(context.Background()); err != nil || len(posts) != 1 {
+				t.Errorf("expected one post, got %v, %v", posts, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("expected concurrent misses to share one load, got %d", n)
+	}
+}
+
+func TestPostService_GetServesStale(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var title atomic.Value
+	title.Store("old")
+	mockRepo := &mockPostRepo{
+		getFunc: func(ctx context.Context, postId string) (*domain.Post, error) {
+			return &domain.Post{ID: postId, Title: title.Load().(string)}, nil
+		},
+	}
+	c, _ := cache.NewLRU(10)
+	s := NewPostService(mockRepo, c, CacheTTL{Soft: time.Millisecond, Hard: time.Minute}, nil, authz.DefaultPolicy(), logger)
+
+	if _, err := s.Get(context.Background(), "1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	title.Store("new")
+
+	got, err := s.Get(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Title != "old" {
+		t.Fatalf("expected the stale post while it is refreshed, got %q", got.Title)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for got.Title != "new" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the stale post to be refreshed in the background")
+		}
+		time.Sleep(5 * time.Millisecond)
+		got, _ = s.Get(context.Background(), "1")
+	}
+}
+
+func TestPostService_GetWithoutHardTTL(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var loads atomic.Int32
+	mockRepo := &mockPostRepo{
+		getFunc: func(ctx context.Context, postId string) (*domain.Post, error) {
+			loads.Add(1)
+			return &domain.Post{ID: postId}, nil
+		},
+	}
+	c, _ := cache.NewLRU(10)
+	s := NewPostService(mockRepo, c, CacheTTL{Soft: time.Minute}, nil, authz.DefaultPolicy(), logger)
+
+	for range 3 {
+		if _, err := s.Get(context.Background(), "1"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("expected the post to stay fresh for Soft, got %d loads", n)
+	}
+}
+
+func TestPostService_UpdateDuringLoad(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	owner := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice"})
+
+	var mu sync.Mutex
+	post := domain.Post{ID: "1", Title: "first", Content: "test", Author: "alice"}
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	mockRepo := &mockPostRepo{
+		getFunc: func(ctx context.Context, postId string) (*domain.Post, error) {
+			mu.Lock()
+			p := post
+			mu.Unlock()
+			// The first load reads the post and stalls before it is cached.
+			if calls.Add(1) == 1 {
+				close(started)
+				<-release
+			}
+			return &p, nil
+		},
+		updateFunc: func(ctx context.Context, postId, title, content string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			post.Title, post.Content = title, content
+			return nil
+		},
+	}
+	c, _ := cache.NewLRU(10)
+	s := NewPostService(mockRepo, c, testCacheTTL, nil, authz.DefaultPolicy(), logger)
+
+	done := make(chan error)
+	go func() {
+		_, err := s.Get(context.Background(), "1")
+		done <- err
+	}()
+	<-started
+
+	if _, err := s.Update(owner, "1", "changed", "test"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := s.Get(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Title != "changed" {
+		t.Fatalf("expected the load that began before the update to stay out of the cache, got %q", got.Title)
+	}
+}
+
+func TestPostService_GetRecoversPanics(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	mockRepo := &mockPostRepo{
+		getFunc: func(ctx context.Context, postId string) (*domain.Post, error) {
+			panic("boom")
+		},
+		getListFunc: func(ctx context.Context) ([]*domain.Post, error) {
+			return []*domain.Post{{ID: "1"}}, nil
+		},
+		getByIDsFunc: func(ctx context.Context, ids []string) ([]*domain.Post, error) {
+			panic("boom")
+		},
+	}
+	c, _ := cache.NewLRU(10)
+	s := NewPostService(mockRepo, c, testCacheTTL, nil, authz.DefaultPolicy(), logger)
+
+	if _, err := s.Get(context.Background(), "1"); err == nil {
+		t.Fatal("expected the panic to be returned as an error")
+	}
+
+	// The list load caches the post; dropping it makes the next list load
+	// it by id.
+	if _, err := s.GetList(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = c.Delete(context.Background(), postKey("1"))
+	if _, err := s.GetList(context.Background()); err == nil {
+		t.Fatal("expected the panic to be returned as an error")
+	}
+}
+
 func TestPostService_GetList(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
 	ctx := context.Background()
@@ -156,23 +551,14 @@ func TestPostService_GetList(t *testing.T) {
 		{ID: "1", Title: "test", Content: "test", Author: "test"},
 		{ID: "2", Title: "test2", Content: "test2", Author: "test2"},
 	}
-	comments := []*domain.Comment{
-		{ID: "c1", PostID: "1", Text: "test"},
-		{ID: "c2", PostID: "2", Text: "test2"},
-	}
 
 	mockRepo := &mockPostRepo{
 		getListFunc: func(ctx context.Context) ([]*domain.Post, error) {
 			return posts, nil
 		},
 	}
-	mockComments := &mockCommentRepo{
-		getByPostIDsFunc: func(ctx context.Context, postIDs []string) ([]*domain.Comment, error) {
-			return comments, nil
-		},
-	}
 
-	s := NewPostService(mockRepo, mockComments, nil, logger)
+	s := NewPostService(mockRepo, nil, testCacheTTL, nil, authz.DefaultPolicy(), logger)
 
 	got, err := s.GetList(ctx)
 	if err != nil {
@@ -181,15 +567,127 @@ func TestPostService_GetList(t *testing.T) {
 	if len(got) != 2 {
 		t.Errorf("expected 2 posts, got %d", len(got))
 	}
-	if len(got[0].Comments) != 1 {
-		t.Errorf("expected 1 comment for first post, got %d", len(got[0].Comments))
+}
+
+func TestPostService_GetListComposesCachedPosts(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	owner := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice"})
+
+	posts := map[string]*domain.Post{
+		"1": {ID: "1", Title: "first", Content: "test", Author: "alice"},
+		"2": {ID: "2", Title: "second", Content: "test", Author: "alice"},
+	}
+	var listLoads int
+	var byIDs [][]string
+	mockRepo := &mockPostRepo{
+		getListFunc: func(ctx context.Context) ([]*domain.Post, error) {
+			listLoads++
+			return []*domain.Post{copyPost(posts["2"]), copyPost(posts["1"])}, nil
+		},
+		getByIDsFunc: func(ctx context.Context, ids []string) ([]*domain.Post, error) {
+			byIDs = append(byIDs, ids)
+			var res []*domain.Post
+			for _, id := range ids {
+				res = append(res, copyPost(posts[id]))
+			}
+			return res, nil
+		},
+		getFunc: func(ctx context.Context, postId string) (*domain.Post, error) {
+			return copyPost(posts[postId]), nil
+		},
+		updateFunc: func(ctx context.Context, postId, title, content string) error {
+			posts[postId].Title = title
+			return nil
+		},
+	}
+	c, _ := cache.NewLRU(10)
+	s := NewPostService(mockRepo, c, testCacheTTL, nil, authz.DefaultPolicy(), logger)
+
+	list := func() []*domain.Post {
+		t.Helper()
+		got, err := s.GetList(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 2 || got[0].ID != "2" || got[1].ID != "1" {
+			t.Fatalf("expected posts 2 and 1, got %+v", got)
+		}
+		return got
+	}
+
+	list()
+	list()
+	if listLoads != 1 || len(byIDs) != 0 {
+		t.Fatalf("expected one list load and no post loads, got %d and %v", listLoads, byIDs)
+	}
+
+	if _, err := s.Update(owner, "1", "changed", "test"); err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+	got := list()
+	if got[1].Title != "changed" {
+		t.Fatalf("expected the updated post in the list, got %q", got[1].Title)
+	}
+	if listLoads != 1 || len(byIDs) != 1 || len(byIDs[0]) != 1 || byIDs[0][0] != "1" {
+		t.Fatalf("expected only the updated post to be reloaded, got %d list loads and %v", listLoads, byIDs)
+	}
+
+	if err := s.Create(context.Background(), &domain.Post{Title: "third", Content: "test", Author: "alice"}); err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	list()
+	if listLoads != 2 {
+		t.Fatalf("expected a new post to reload the list, got %d list loads", listLoads)
+	}
+}
+
+func TestPostService_PublishesEvents(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	owner := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice"})
+
+	post := &domain.Post{ID: "1", Title: "test", Content: "test", Author: "alice", Flag: true}
+	mockRepo := &mockPostRepo{
+		getFunc: func(ctx context.Context, postId string) (*domain.Post, error) {
+			return copyPost(post), nil
+		},
+		updateFunc: func(ctx context.Context, postId, title, content string) error {
+			post.Title = title
+			return nil
+		},
+	}
+	bus, evs := subscribeEvents(t, "1")
+	s := NewPostService(mockRepo, nil, testCacheTTL, bus, authz.DefaultPolicy(), logger)
+
+	if err := s.SetFlag(owner, "1", false); err != nil {
+		t.Fatalf("SetFlag: unexpected error: %v", err)
+	}
+	if e := expectEvent(t, evs, events.CommentsToggled); e.CommentsAllowed {
+		t.Fatalf("expected comments to be disabled, got %+v", e)
+	}
+
+	if _, err := s.Update(owner, "1", "changed", "test"); err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+	if e := expectEvent(t, evs, events.PostUpdated); e.Post == nil || e.Post.Title != "changed" {
+		t.Fatalf("expected the updated post, got %+v", e)
 	}
 }
 
+func copyPost(p *domain.Post) *domain.Post {
+	c := *p
+	return &c
+}
+
 func TestPostService_SetFlag(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
-	ctx := context.Background()
+	owner := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice"})
 	mockRepo := &mockPostRepo{
+		getFunc: func(ctx context.Context, postId string) (*domain.Post, error) {
+			if postId == "missing" {
+				return nil, errors.New("post not found")
+			}
+			return &domain.Post{ID: postId, Author: "alice"}, nil
+		},
 		setFlagFunc: func(ctx context.Context, postId string, flag bool) error {
 			if postId == "error" {
 				return errors.New("repo error")
@@ -198,26 +696,224 @@ func TestPostService_SetFlag(t *testing.T) {
 		},
 	}
 
-	s := NewPostService(mockRepo, nil, nil, logger)
+	s := NewPostService(mockRepo, nil, testCacheTTL, nil, authz.DefaultPolicy(), logger)
 
-	t.Run("success", func(t *testing.T) {
-		err := s.SetFlag(ctx, "1", false)
+	t.Run("owner", func(t *testing.T) {
+		err := s.SetFlag(owner, "1", false)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 	})
 
+	t.Run("moderator", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "bob", Roles: []string{authz.RoleModerator}})
+		if err := s.SetFlag(ctx, "1", false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("other user", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "bob"})
+		if err := s.SetFlag(ctx, "1", false); !errors.Is(err, domain.ErrForbidden) {
+			t.Fatalf("expected ErrForbidden, got %v", err)
+		}
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		if err := s.SetFlag(context.Background(), "1", false); !errors.Is(err, auth.ErrUnauthenticated) {
+			t.Fatalf("expected ErrUnauthenticated, got %v", err)
+		}
+	})
+
 	t.Run("empty postId", func(t *testing.T) {
-		err := s.SetFlag(ctx, "", true)
+		err := s.SetFlag(owner, "", true)
 		if err == nil {
 			t.Fatal("expected error for empty postId")
 		}
 	})
 
+	t.Run("post not found", func(t *testing.T) {
+		if err := s.SetFlag(owner, "missing", true); err == nil {
+			t.Fatal("expected error for unknown post")
+		}
+	})
+
 	t.Run("repo error", func(t *testing.T) {
-		err := s.SetFlag(ctx, "error", true)
+		err := s.SetFlag(owner, "error", true)
 		if err == nil {
 			t.Fatal("expected error from repo")
 		}
 	})
 }
+
+func TestPostService_Update(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	owner := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice"})
+	var updates []string
+	mockRepo := &mockPostRepo{
+		getFunc: func(ctx context.Context, postId string) (*domain.Post, error) {
+			if postId == "missing" {
+				return nil, errors.New("post not found")
+			}
+			return &domain.Post{ID: postId, Title: "title", Content: "content", Author: "alice"}, nil
+		},
+		updateFunc: func(ctx context.Context, postId, title, content string) error {
+			updates = append(updates, title)
+			return nil
+		},
+	}
+
+	s := NewPostService(mockRepo, nil, testCacheTTL, nil, authz.DefaultPolicy(), logger)
+
+	t.Run("owner", func(t *testing.T) {
+		updates = nil
+		if _, err := s.Update(owner, "1", "new title", "content"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(updates) != 1 || updates[0] != "new title" {
+			t.Fatalf("expected one update, got %v", updates)
+		}
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		updates = nil
+		post, err := s.Update(owner, "1", "title", "content")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if post.ID != "1" || len(updates) != 0 {
+			t.Fatalf("expected no new revision, got %v", updates)
+		}
+	})
+
+	t.Run("other user", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "bob", Roles: []string{authz.RoleModerator}})
+		if _, err := s.Update(ctx, "1", "new title", "content"); !errors.Is(err, domain.ErrForbidden) {
+			t.Fatalf("expected ErrForbidden, got %v", err)
+		}
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		if _, err := s.Update(context.Background(), "1", "new title", "content"); !errors.Is(err, auth.ErrUnauthenticated) {
+			t.Fatalf("expected ErrUnauthenticated, got %v", err)
+		}
+	})
+
+	t.Run("missing fields", func(t *testing.T) {
+		if _, err := s.Update(owner, "1", "", "content"); err == nil {
+			t.Fatal("expected error for empty title")
+		}
+	})
+
+	t.Run("post not found", func(t *testing.T) {
+		if _, err := s.Update(owner, "missing", "new title", "content"); err == nil {
+			t.Fatal("expected error for unknown post")
+		}
+	})
+}
+
+func TestPostService_Revert(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	owner := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice"})
+	var updated string
+	mockRepo := &mockPostRepo{
+		getFunc: func(ctx context.Context, postId string) (*domain.Post, error) {
+			return &domain.Post{ID: postId, Title: "v3", Content: "content", Author: "alice", Revision: 3}, nil
+		},
+		getRevisionFunc: func(ctx context.Context, postId string, revision int) (*domain.PostRevision, error) {
+			if revision != 1 {
+				return nil, errors.New("revision not found")
+			}
+			return &domain.PostRevision{PostID: postId, Revision: 1, Title: "v1", Content: "old content"}, nil
+		},
+		updateFunc: func(ctx context.Context, postId, title, content string) error {
+			updated = title + "/" + content
+			return nil
+		},
+	}
+
+	s := NewPostService(mockRepo, nil, testCacheTTL, nil, authz.DefaultPolicy(), logger)
+
+	t.Run("owner", func(t *testing.T) {
+		if _, err := s.Revert(owner, "1", 1); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if updated != "v1/old content" {
+			t.Fatalf("expected revision 1 to be restored, got %q", updated)
+		}
+	})
+
+	t.Run("revision not found", func(t *testing.T) {
+		if _, err := s.Revert(owner, "1", 7); err == nil {
+			t.Fatal("expected error for unknown revision")
+		}
+	})
+
+	t.Run("other user", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "bob"})
+		if _, err := s.Revert(ctx, "1", 1); !errors.Is(err, domain.ErrForbidden) {
+			t.Fatalf("expected ErrForbidden, got %v", err)
+		}
+	})
+}
+
+func TestPostService_GetRevisionsByPostIDs(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mockRepo := &mockPostRepo{
+		getRevisionsByPostIDsFunc: func(ctx context.Context, postIDs []string) ([]*domain.PostRevision, error) {
+			return []*domain.PostRevision{
+				{PostID: "1", Revision: 1},
+				{PostID: "1", Revision: 2},
+				{PostID: "2", Revision: 1},
+			}, nil
+		},
+	}
+
+	s := NewPostService(mockRepo, nil, testCacheTTL, nil, authz.DefaultPolicy(), logger)
+
+	got, err := s.GetRevisionsByPostIDs(context.Background(), []string{"1", "2", "3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got["1"]) != 2 || len(got["2"]) != 1 || len(got["3"]) != 0 {
+		t.Fatalf("unexpected grouping: %v", got)
+	}
+}
+
+func TestPostService_GetPage(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+	author := "alice"
+
+	mockRepo := &mockPostRepo{
+		getPageFunc: func(ctx context.Context, filter domain.PostFilter, order domain.PostOrder, window pagination.Window) ([]*domain.Post, error) {
+			if order != domain.PostOrderNewest {
+				t.Fatalf("expected default order %s, got %s", domain.PostOrderNewest, order)
+			}
+			if filter.Author == nil || *filter.Author != author {
+				t.Fatalf("expected author filter %q, got %v", author, filter.Author)
+			}
+			return []*domain.Post{{ID: "1"}, {ID: "2"}, {ID: "3"}}, nil
+		},
+	}
+
+	s := NewPostService(mockRepo, nil, testCacheTTL, nil, authz.DefaultPolicy(), logger)
+
+	t.Run("success", func(t *testing.T) {
+		first := 2
+		page, err := s.GetPage(ctx, domain.PostFilter{Author: &author}, "", pagination.Args{First: &first})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(page.Items) != 2 || !page.HasNextPage {
+			t.Fatalf("expected 2 posts and a next page, got %d posts, next=%v", len(page.Items), page.HasNextPage)
+		}
+	})
+
+	t.Run("unknown order", func(t *testing.T) {
+		_, err := s.GetPage(ctx, domain.PostFilter{}, "RANDOM", pagination.Args{})
+		if err == nil {
+			t.Fatal("expected error for unknown order")
+		}
+	})
+}
@@ -0,0 +1,5 @@
+package service
+
+import "github.com/limon4ik-black/graphql-comments-system.git/internal/tracing"
+
+var tracer = tracing.Tracer("service")
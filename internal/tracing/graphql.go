@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var graphqlTracer = Tracer("graph")
+
+// GraphQL is a gqlgen extension that starts a span for every operation
+// response and a child span for every field with a resolver. Trace context
+// sent by the client in the request headers is continued.
+type GraphQL struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
+} = GraphQL{}
+
+func (GraphQL) ExtensionName() string { return "Tracing" }
+
+func (GraphQL) Validate(graphql.ExecutableSchema) error { return nil }
+
+func (GraphQL) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	oc := graphql.GetOperationContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(oc.Headers))
+
+	name, typ := oc.OperationName, "operation"
+	if oc.Operation != nil {
+		typ = string(oc.Operation.Operation)
+		if name == "" {
+			name = oc.Operation.Name
+		}
+	}
+	spanName := typ
+	if name != "" {
+		spanName += " " + name
+	}
+
+	ctx, span := graphqlTracer.Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("graphql.operation.name", name),
+			attribute.String("graphql.operation.type", typ),
+		),
+	)
+	defer span.End()
+
+	resp := next(ctx)
+	if resp != nil && len(resp.Errors) > 0 {
+		span.SetStatus(codes.Error, resp.Errors.Error())
+	}
+	return resp
+}
+
+func (GraphQL) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+
+	ctx, span := graphqlTracer.Start(ctx, fmt.Sprintf("%s.%s", fc.Object, fc.Field.Name),
+		trace.WithAttributes(
+			attribute.String("graphql.field.path", fc.Path().String()),
+		),
+	)
+	res, err := next(ctx)
+	End(span, &err)
+	return res, err
+}
@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var redisTracer = Tracer("redis")
+
+// RedisHook starts a client span for every Redis command and pipeline.
+// Install it with client.AddHook(tracing.RedisHook{}).
+type RedisHook struct{}
+
+var _ redis.Hook = RedisHook{}
+
+func (RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		ctx, span := redisTracer.Start(ctx, "redis.dial", trace.WithSpanKind(trace.SpanKindClient))
+		conn, err := next(ctx, network, addr)
+		End(span, &err)
+		return conn, err
+	}
+}
+
+func (RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := redisTracer.Start(ctx, "redis."+cmd.Name(),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system.name", "redis"),
+				attribute.String("db.operation.name", cmd.Name()),
+			),
+		)
+		err := next(ctx, cmd)
+		endRedis(span, err)
+		return err
+	}
+}
+
+func (RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		names := make([]string, 0, len(cmds))
+		for _, cmd := range cmds {
+			names = append(names, cmd.Name())
+		}
+		ctx, span := redisTracer.Start(ctx, "redis.pipeline",
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system.name", "redis"),
+				attribute.String("db.operation.name", strings.Join(names, " ")),
+			),
+		)
+		err := next(ctx, cmds)
+		endRedis(span, err)
+		return err
+	}
+}
+
+// endRedis ends span; a missing key is a normal cache miss, not an error.
+func endRedis(span trace.Span, err error) {
+	if errors.Is(err, redis.Nil) {
+		span.SetAttributes(attribute.Bool("redis.miss", true))
+		err = nil
+	}
+	End(span, &err)
+}
@@ -0,0 +1,88 @@
+// Package tracing sets up OpenTelemetry tracing and instruments the parts
+// of the request pipeline that have no instrumentation of their own.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Setup installs the global tracer provider. exporter is one of the
+// Exporter constants; endpoint is the host:port of the OTLP/HTTP collector
+// and is only used by ExporterOTLP. The returned function flushes pending
+// spans and must be called on shutdown.
+func Setup(ctx context.Context, exporter, endpoint, serviceName string) (func(context.Context) error, error) {
+	var exp sdktrace.SpanExporter
+	switch exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		e, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, err
+		}
+		exp = e
+	case ExporterOTLP:
+		e, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithInsecure())
+		if err != nil {
+			return nil, err
+		}
+		exp = e
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer of an instrumented package. It follows the
+// global provider, so package-level tracers may be created before Setup.
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer("github.com/limon4ik-black/graphql-comments-system.git/" + name)
+}
+
+// End records err on span, if any, and ends it. Use it with a named error
+// result as defer tracing.End(span, &err).
+func End(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+// ID returns an attribute for the id of an entity, e.g. ID("post", id)
+// gives post.id.
+func ID(entity, id string) attribute.KeyValue {
+	return attribute.String(entity+".id", id)
+}
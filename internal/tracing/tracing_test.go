@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newRecorder() (*tracetest.SpanRecorder, *sdktrace.TracerProvider) {
+	recorder := tracetest.NewSpanRecorder()
+	return recorder, sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+}
+
+func TestEnd(t *testing.T) {
+	recorder, provider := newRecorder()
+	tracer := provider.Tracer("test")
+
+	_, ok := tracer.Start(context.Background(), "ok")
+	var err error
+	End(ok, &err)
+
+	_, failed := tracer.Start(context.Background(), "failed")
+	err = errors.New("boom")
+	End(failed, &err)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 ended spans, got %d", len(spans))
+	}
+	if spans[0].Status().Code != codes.Unset {
+		t.Fatalf("expected unset status, got %v", spans[0].Status())
+	}
+	if spans[1].Status().Code != codes.Error || spans[1].Status().Description != "boom" {
+		t.Fatalf("expected error status, got %v", spans[1].Status())
+	}
+}
+
+func TestRedisHook_MissIsNotAnError(t *testing.T) {
+	recorder, provider := newRecorder()
+	_, span := provider.Tracer("test").Start(context.Background(), "redis.get")
+	endRedis(span, redis.Nil)
+
+	got := recorder.Ended()[0]
+	if got.Status().Code == codes.Error {
+		t.Fatalf("expected a cache miss not to be an error, got %v", got.Status())
+	}
+}
+
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup(context.Background(), ExporterNone, "", "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+
+	if _, err := Setup(context.Background(), "zipkin", "", "test"); err == nil {
+		t.Fatal("expected error for unknown exporter")
+	}
+}
@@ -1,8 +1,122 @@
+// Package validation normalizes and checks user input before it is stored.
 package validation
 
-import "github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
-func PostValidation(post *domain.Post) bool {
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+)
 
-	return false
+const (
+	MaxTitleLength = 200
+	// MaxCommentLength matches the CHECK constraint on comments.text.
+	MaxCommentLength = 2000
+)
+
+// FieldError describes why the value of one input field was rejected.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Errors lists every invalid field of an input. It matches
+// domain.ErrValidation.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e Errors) Unwrap() error { return domain.ErrValidation }
+
+func (e *Errors) add(field, message string) {
+	*e = append(*e, FieldError{Field: field, Message: message})
+}
+
+// err returns e as an error, or nil if there are no field errors.
+func (e Errors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Post trims the title and content of post and checks them.
+func Post(post *domain.Post) error {
+	var errs Errors
+	post.Title = strings.TrimSpace(post.Title)
+	post.Content = strings.TrimSpace(post.Content)
+
+	errs.text("title", post.Title, MaxTitleLength, false)
+	errs.text("content", post.Content, 0, true)
+	if post.Author == "" {
+		errs.add("author", "is required")
+	}
+	return errs.err()
+}
+
+// Comment trims the text of comment and checks it.
+func Comment(comment *domain.Comment) error {
+	var errs Errors
+	comment.Text = strings.TrimSpace(comment.Text)
+
+	if comment.PostID == "" {
+		errs.add("postID", "is required")
+	}
+	errs.text("text", comment.Text, MaxCommentLength, true)
+	if comment.Author == "" {
+		errs.add("author", "is required")
+	}
+	return errs.err()
+}
+
+// CommentText trims and checks the text of a new or edited comment.
+func CommentText(text string) (string, error) {
+	var errs Errors
+	text = strings.TrimSpace(text)
+	errs.text("text", text, MaxCommentLength, true)
+	return text, errs.err()
+}
+
+// Parent checks that comment may be posted as a reply to parent. maxDepth
+// limits how deep replies may be nested, 0 means no limit.
+func Parent(comment, parent *domain.Comment, maxDepth int) error {
+	var errs Errors
+	switch {
+	case parent.PostID != comment.PostID:
+		errs.add("parentID", "must belong to the same post")
+	case maxDepth > 0 && parent.Depth >= maxDepth:
+		errs.add("parentID", fmt.Sprintf("replies may be nested at most %d levels deep", maxDepth))
+	}
+	return errs.err()
+}
+
+// MissingParent reports a parentID that does not refer to any comment.
+func MissingParent() error {
+	return Errors{{Field: "parentID", Message: "does not exist"}}
+}
+
+// text checks a required text field. maxLength counts characters, 0 means
+// no limit. Multiline fields may contain newlines and tabs but no other
+// control characters.
+func (e *Errors) text(field, value string, maxLength int, multiline bool) {
+	switch {
+	case value == "":
+		e.add(field, "is required")
+	case !utf8.ValidString(value):
+		e.add(field, "must be valid UTF-8")
+	case maxLength > 0 && utf8.RuneCountInString(value) > maxLength:
+		e.add(field, fmt.Sprintf("must be at most %d characters", maxLength))
+	case strings.ContainsFunc(value, func(r rune) bool {
+		return unicode.IsControl(r) && !(multiline && (r == '\n' || r == '\r' || r == '\t'))
+	}):
+		e.add(field, "must not contain control characters")
+	}
 }
@@ -0,0 +1,105 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/limon4ik-black/graphql-comments-system.git/internal/domain"
+)
+
+func TestPost(t *testing.T) {
+	cases := []struct {
+		name       string
+		post       domain.Post
+		wantFields []string
+	}{
+		{"valid", domain.Post{Title: "title", Content: "line 1\nline 2", Author: "alice"}, nil},
+		{"empty", domain.Post{}, []string{"title", "content", "author"}},
+		{"blank", domain.Post{Title: "  ", Content: "\n\t", Author: "alice"}, []string{"title", "content"}},
+		{"long title", domain.Post{Title: strings.Repeat("я", MaxTitleLength+1), Content: "content", Author: "alice"}, []string{"title"}},
+		{"newline in title", domain.Post{Title: "a\nb", Content: "content", Author: "alice"}, []string{"title"}},
+		{"control character", domain.Post{Title: "title", Content: "a\x00b", Author: "alice"}, []string{"content"}},
+		{"invalid utf-8", domain.Post{Title: "title\xff", Content: "content", Author: "alice"}, []string{"title"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			post := tc.post
+			assertFields(t, Post(&post), tc.wantFields...)
+		})
+	}
+}
+
+func TestPost_Trims(t *testing.T) {
+	post := &domain.Post{Title: "  title ", Content: "\ncontent\n", Author: "alice"}
+	if err := Post(post); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if post.Title != "title" || post.Content != "content" {
+		t.Fatalf("expected trimmed fields, got %q and %q", post.Title, post.Content)
+	}
+}
+
+func TestComment(t *testing.T) {
+	cases := []struct {
+		name       string
+		comment    domain.Comment
+		wantFields []string
+	}{
+		{"valid", domain.Comment{PostID: "p1", Text: "hello\n\tworld", Author: "alice"}, nil},
+		{"empty", domain.Comment{}, []string{"postID", "text", "author"}},
+		{"max length", domain.Comment{PostID: "p1", Text: strings.Repeat("я", MaxCommentLength), Author: "alice"}, nil},
+		{"too long", domain.Comment{PostID: "p1", Text: strings.Repeat("я", MaxCommentLength+1), Author: "alice"}, []string{"text"}},
+		{"control character", domain.Comment{PostID: "p1", Text: "bell\a", Author: "alice"}, []string{"text"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			comment := tc.comment
+			assertFields(t, Comment(&comment), tc.wantFields...)
+		})
+	}
+}
+
+func TestCommentText(t *testing.T) {
+	text, err := CommentText("  hello  ")
+	if err != nil || text != "hello" {
+		t.Fatalf("expected trimmed text, got %q, %v", text, err)
+	}
+	_, err = CommentText(" ")
+	assertFields(t, err, "text")
+}
+
+func TestParent(t *testing.T) {
+	comment := &domain.Comment{PostID: "p1"}
+	if err := Parent(comment, &domain.Comment{PostID: "p1", Depth: 1}, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertFields(t, Parent(comment, &domain.Comment{PostID: "p2", Depth: 1}, 2), "parentID")
+	assertFields(t, Parent(comment, &domain.Comment{PostID: "p1", Depth: 2}, 2), "parentID")
+	assertFields(t, Parent(comment, &domain.Comment{PostID: "p1", Depth: 50}, 0))
+}
+
+func assertFields(t *testing.T, err error, want ...string) {
+	t.Helper()
+	if len(want) == 0 {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+
+	if !errors.Is(err, domain.ErrValidation) {
+		t.Fatalf("expected ErrValidation, got %v", err)
+	}
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected Errors, got %T", err)
+	}
+	var got []string
+	for _, fe := range errs {
+		got = append(got, fe.Field)
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected errors for %v, got %v", want, errs)
+	}
+}
@@ -0,0 +1,4 @@
+ALTER TABLE comments
+    DROP COLUMN edited,
+    DROP COLUMN deleted_at,
+    DROP COLUMN updated_at;
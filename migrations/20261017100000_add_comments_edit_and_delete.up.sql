@@ -0,0 +1,10 @@
+ALTER TABLE comments
+    ADD COLUMN updated_at TIMESTAMP,
+    ADD COLUMN deleted_at TIMESTAMP,
+    ADD COLUMN edited BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE comments SET updated_at = created_at;
+
+ALTER TABLE comments
+    ALTER COLUMN updated_at SET NOT NULL,
+    ALTER COLUMN updated_at SET DEFAULT NOW();
@@ -0,0 +1,13 @@
+CREATE TABLE comment_votes (
+    comment_id TEXT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    value SMALLINT NOT NULL CHECK (value IN (-1, 1)),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (comment_id, user_id)
+);
+
+CREATE INDEX idx_comment_votes_user_id ON comment_votes(user_id);
+
+ALTER TABLE comments
+    ADD COLUMN upvotes INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN downvotes INTEGER NOT NULL DEFAULT 0;
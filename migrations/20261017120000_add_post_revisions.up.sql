@@ -0,0 +1,18 @@
+ALTER TABLE posts
+    ADD COLUMN revision INTEGER NOT NULL DEFAULT 1,
+    ADD COLUMN updated_at TIMESTAMP;
+
+UPDATE posts SET updated_at = created_at;
+
+ALTER TABLE posts
+    ALTER COLUMN updated_at SET NOT NULL,
+    ALTER COLUMN updated_at SET DEFAULT NOW();
+
+CREATE TABLE post_revisions (
+    post_id TEXT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (post_id, revision)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE comments
+    DROP COLUMN locked,
+    DROP COLUMN depth;
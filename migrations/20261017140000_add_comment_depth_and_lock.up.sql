@@ -0,0 +1,10 @@
+ALTER TABLE comments
+    ADD COLUMN depth INTEGER NOT NULL DEFAULT 1 CHECK (depth >= 1),
+    ADD COLUMN locked BOOLEAN NOT NULL DEFAULT FALSE;
+
+WITH RECURSIVE tree AS (
+    SELECT id, 1 AS depth FROM comments WHERE parent_id IS NULL
+    UNION ALL
+    SELECT c.id, t.depth + 1 FROM comments c JOIN tree t ON c.parent_id = t.id
+)
+UPDATE comments SET depth = tree.depth FROM tree WHERE comments.id = tree.id;